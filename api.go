@@ -2,15 +2,20 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"time"
 	"uprpc/cli"
 	"uprpc/proto"
 
+	"github.com/jhump/protoreflect/desc"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 type Api struct {
-	ctx context.Context
-	cli *cli.Client
+	ctx     context.Context
+	cli     *cli.Client
+	watcher *proto.Watcher
 }
 
 func newApi() *Api {
@@ -20,6 +25,10 @@ func newApi() *Api {
 func (api *Api) startup(ctx context.Context) {
 	api.ctx = ctx
 	api.cli = cli.New(ctx)
+	api.watcher = proto.NewWatcher(time.Second, func(reload *proto.Reload) {
+		runtime.EventsEmit(ctx, "reload", reload)
+	})
+	go api.watcher.Run(ctx)
 }
 
 type R struct {
@@ -41,10 +50,160 @@ func (api *Api) ParseProto(fileNames []string, includeDirs []string) R {
 	if err != nil {
 		return R{Success: false, Message: err.Error()}
 	} else {
+		for _, file := range files {
+			api.watcher.Watch(file, includeDirs)
+		}
 		return R{Success: true, Data: files}
 	}
 }
 
+func (api *Api) Unwatch(path string) R {
+	api.watcher.Unwatch(path)
+	return R{Success: true}
+}
+
+func (api *Api) ReconcileBody(serviceFullyName, methodName, body string) R {
+	reconciled, err := proto.ReconcileBody(serviceFullyName, methodName, body)
+	if err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	return R{Success: true, Data: reconciled}
+}
+
+// RequestTemplate renders a request body in one of the proto.Template modes
+func (api *Api) RequestTemplate(serviceFullyName, methodName, mode string) R {
+	body, err := proto.RequestTemplate(serviceFullyName, methodName, mode)
+	if err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	return R{Success: true, Data: body}
+}
+
+// GetInputSchema returns the JSON Schema of the request body of a method
+func (api *Api) GetInputSchema(methodId string) R {
+	schema, err := proto.InputSchema(methodId)
+	if err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	return R{Success: true, Data: schema}
+}
+
+// ListSymbols lists the elements of the loaded files, all kinds when kind is empty
+func (api *Api) ListSymbols(kind string) R {
+	return R{Success: true, Data: proto.Symbols(kind)}
+}
+
+func (api *Api) SearchSymbols(query string) R {
+	return R{Success: true, Data: proto.SearchSymbols(query)}
+}
+
+func (api *Api) DescribeSymbol(fullName string) R {
+	detail, err := proto.DescribeSymbol(fullName)
+	if err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	return R{Success: true, Data: detail}
+}
+
+func (api *Api) FindUsages(fullName string) R {
+	usages, err := proto.FindUsages(fullName)
+	if err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	return R{Success: true, Data: usages}
+}
+
+func (api *Api) SymbolSource(fullName string) R {
+	source, err := proto.SymbolSource(fullName)
+	if err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	return R{Success: true, Data: source}
+}
+
+// DecodeWire annotates the fields of base64, hex or file encoded message bytes as the
+// message type. Without a type the bytes are decoded like protoc --decode_raw, guessing
+// nested messages and strings.
+func (api *Api) DecodeWire(data, format, messageType string) R {
+	b, err := cli.ReadBinary(data, format)
+	if err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	var md *desc.MessageDescriptor
+	if messageType != "" {
+		if md = proto.FindMessage(messageType); md == nil {
+			return R{Success: false, Message: fmt.Sprintf("message %s not found", messageType)}
+		}
+	}
+	fields, err := proto.DecodeWire(b, md)
+	if err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	return R{Success: true, Data: fields}
+}
+
+// OpenBinary selects a file of message bytes for a body in the file format
+func (api *Api) OpenBinary() R {
+	selection, err := runtime.OpenFileDialog(api.ctx, runtime.OpenDialogOptions{
+		Title:   "Open Binary Message",
+		Filters: []runtime.FileFilter{{DisplayName: "binary message (*.bin, *.pb)", Pattern: "*.bin;*.pb;*.binpb"}},
+	})
+	if err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	return R{Success: true, Data: selection}
+}
+
+// SaveBinary writes a response rendered in the base64 format as raw bytes
+func (api *Api) SaveBinary(data string) R {
+	b, err := cli.ReadBinary(data, cli.FormatBase64)
+	if err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	path, err := runtime.SaveFileDialog(api.ctx, runtime.SaveDialogOptions{Title: "Save Binary Message", DefaultFilename: "response.bin"})
+	if err != nil || path == "" {
+		return R{Success: false, Message: "no file selected"}
+	}
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	return R{Success: true, Data: path}
+}
+
+func (api *Api) ParseBuf(dir string) R {
+	ws, err := proto.ParseBuf(dir)
+	if err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	api.watcher.WatchWorkspace(ws)
+	return R{Success: true, Data: ws}
+}
+
+func (api *Api) ImportDir(dir string) R {
+	result, err := proto.ImportDir(dir)
+	if err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	api.watcher.WatchDir(result)
+	return R{Success: true, Data: result}
+}
+
+func (api *Api) Breaking(oldSchema, newSchema cli.Schema) R {
+	changes, err := api.cli.Breaking(&oldSchema, &newSchema)
+	if err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	return R{Success: true, Data: changes}
+}
+
+func (api *Api) VerifyServer(req cli.RequestData, services []string) R {
+	diffs, err := api.cli.Verify(&req, services)
+	if err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	return R{Success: true, Data: diffs}
+}
+
 func (api *Api) Send(req cli.RequestData) R {
 	runtime.LogPrintf(api.ctx, "send request data: %+v", req)
 	api.cli.Send(&req)
@@ -61,3 +220,29 @@ func (api *Api) Stop(id string) R {
 	api.cli.Stop(id)
 	return R{Success: true, Data: nil}
 }
+
+func (api *Api) ListConns() R {
+	return R{Success: true, Data: api.cli.Conns()}
+}
+
+func (api *Api) Reconnect(key string) R {
+	if err := api.cli.Reconnect(key); err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	return R{Success: true, Data: api.cli.Conns()}
+}
+
+func (api *Api) History() R {
+	return R{Success: true, Data: api.cli.History()}
+}
+
+func (api *Api) SetProxy(proxy cli.Proxy) R {
+	if err := api.cli.SetProxy(&proxy); err != nil {
+		return R{Success: false, Message: err.Error()}
+	}
+	return R{Success: true}
+}
+
+func (api *Api) GetProxy() R {
+	return R{Success: true, Data: api.cli.Proxy()}
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+)
+
+// ReadBinary returns the wire bytes of a base64 or hex string, or of the file at the path
+// data when format is FormatFile
+func ReadBinary(data, format string) ([]byte, error) {
+	switch format {
+	case FormatBase64:
+		data = strings.Join(strings.Fields(data), "")
+		// captured payloads are often url-safe or unpadded
+		for _, encoding := range []*base64.Encoding{base64.RawStdEncoding, base64.RawURLEncoding} {
+			if b, err := encoding.DecodeString(strings.TrimRight(data, "=")); err == nil {
+				return b, nil
+			}
+		}
+		return nil, errors.New("body is not valid base64")
+	case FormatHex:
+		data = strings.TrimPrefix(strings.Join(strings.Fields(data), ""), "0x")
+		b, err := hex.DecodeString(data)
+		if err != nil {
+			return nil, errors.Wrap(err, "body is not valid hex")
+		}
+		return b, nil
+	case FormatFile:
+		b, err := os.ReadFile(strings.TrimSpace(data))
+		if err != nil {
+			return nil, errors.Wrap(err, "read body file error")
+		}
+		return b, nil
+	default:
+		return nil, errors.Errorf("%s is not a binary format", format)
+	}
+}
+
+// decodeBinaryBody unmarshals wire bytes into msg, bytes with fields the input type does
+// not declare are most likely meant for another type and rejected
+func decodeBinaryBody(msg *dynamic.Message, body, format string) error {
+	b, err := ReadBinary(body, format)
+	if err != nil {
+		return err
+	}
+	if err := msg.Unmarshal(b); err != nil {
+		return errors.Wrapf(err, "body is not a valid %s", msg.GetMessageDescriptor().GetFullyQualifiedName())
+	}
+	if unknown := unknownFields(msg, ""); len(unknown) > 0 {
+		return errors.Errorf("body does not match %s, undeclared fields %s",
+			msg.GetMessageDescriptor().GetFullyQualifiedName(), strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// unknownFields lists the numbers of undeclared fields of msg and its nested messages
+func unknownFields(msg *dynamic.Message, prefix string) []string {
+	var unknown []string
+	for _, number := range msg.GetUnknownFields() {
+		unknown = append(unknown, fmt.Sprintf("%s%d", prefix, number))
+	}
+	for _, fd := range msg.GetKnownFields() {
+		path := prefix + fd.GetName() + "."
+		switch v := msg.GetField(fd).(type) {
+		case *dynamic.Message:
+			unknown = append(unknown, unknownFields(v, path)...)
+		case []interface{}:
+			for _, item := range v {
+				if nested, ok := item.(*dynamic.Message); ok {
+					unknown = append(unknown, unknownFields(nested, path)...)
+				}
+			}
+		case map[interface{}]interface{}:
+			for _, item := range v {
+				if nested, ok := item.(*dynamic.Message); ok {
+					unknown = append(unknown, unknownFields(nested, path)...)
+				}
+			}
+		}
+	}
+	return unknown
+}
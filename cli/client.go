@@ -2,18 +2,16 @@ package cli
 
 import (
 	"context"
-	"fmt"
 	"io"
-	"os"
-	"path"
-	"syscall"
-	"uprpc/pkg/file"
+	"net/http"
+	"sync"
+	uproto "uprpc/proto"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/jhump/protoreflect/desc"
-	"github.com/jhump/protoreflect/desc/protoparse"
 	"github.com/jhump/protoreflect/dynamic"
 	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
@@ -28,26 +26,51 @@ type Metadata struct {
 }
 
 type RequestData struct {
-	Id               string     `json:"id,omitempty"`
-	ProtoPath        string     `json:"protoPath,omitempty"`
-	ServiceName      string     `json:"serviceName,omitempty"`
-	ServiceFullyName string     `json:"serviceFullyName,omitempty"`
-	MethodName       string     `json:"methodName,omitempty"`
-	MethodMode       Mode       `json:"methodMode,omitempty"`
-	Host             string     `json:"host,omitempty"`
-	Body             string     `json:"body,omitempty"`
-	Mds              []Metadata `json:"mds,omitempty"`
-	IncludeDirs      []string   `json:"includeDirs,omitempty"`
+	Id               string       `json:"id,omitempty"`
+	ProtoPath        string       `json:"protoPath,omitempty"`
+	ServiceName      string       `json:"serviceName,omitempty"`
+	ServiceFullyName string       `json:"serviceFullyName,omitempty"`
+	MethodName       string       `json:"methodName,omitempty"`
+	MethodMode       Mode         `json:"methodMode,omitempty"`
+	Host             string       `json:"host,omitempty"`
+	Authority        string       `json:"authority,omitempty"`
+	Proxy            *Proxy       `json:"proxy,omitempty"`
+	Transport        string       `json:"transport,omitempty"`
+	Codec            string       `json:"codec,omitempty"`
+	Http2            bool         `json:"http2,omitempty"`
+	Body             string       `json:"body,omitempty"`
+	Format           string       `json:"format,omitempty"`
+	ResponseFormat   string       `json:"responseFormat,omitempty"`
+	JsonOptions      *JsonOptions `json:"jsonOptions,omitempty"`
+	Mds              []Metadata   `json:"mds,omitempty"`
+	IncludeDirs      []string     `json:"includeDirs,omitempty"`
 }
 
 type ResponseData struct {
-	Id   string     `json:"id"`
-	Body string     `json:"body"`
-	Mds  []Metadata `json:"mds"`
+	Id     string     `json:"id"`
+	Body   string     `json:"body"`
+	Mds    []Metadata `json:"mds"`
+	Status *Status    `json:"status,omitempty"`
+	Timing *Timing    `json:"timing,omitempty"`
 }
 
 type Mode int
 
+const (
+	TransportGrpc        = "grpc"
+	TransportGrpcWeb     = "grpc-web"
+	TransportGrpcWebText = "grpc-web-text"
+	TransportConnect     = "connect"
+	TransportTwirp       = "twirp"
+	// TransportHTTP calls the REST mapping of a method given by its google.api.http option
+	TransportHTTP = "http"
+)
+
+const (
+	CodecJSON  = "json"
+	CodecProto = "proto"
+)
+
 const (
 	Unary Mode = iota
 	ClientStream
@@ -56,28 +79,109 @@ const (
 )
 
 type Client struct {
-	ctx context.Context
+	ctx         context.Context
+	pool        *connPool
+	history     *history
+	mu          sync.Mutex
+	proxy       *Proxy
+	httpClients map[string]*http.Client
 }
 
 type stream struct {
+	req        *RequestData
+	trace      *trace
+	err        error
 	methodMode Mode
 	methodDesc *desc.MethodDescriptor
+	cancel     context.CancelFunc
+	// request body of the http streaming transports
+	body       *io.PipeWriter
+	sendClosed bool
+	codec      string
+	decoder    *fallbackCodec
 	cli        *clientStub
 	cliStream  *grpcdynamic.ClientStream
 	srvStream  *grpcdynamic.ServerStream
 	bidiStream *grpcdynamic.BidiStream
 }
 
-var streams = make(map[string]*stream)
+// streamTable holds the open streams by request id, they are added and removed by the
+// reader goroutines of the transports as well as by the calls of the frontend
+type streamTable struct {
+	mu sync.Mutex
+	m  map[string]*stream
+}
+
+var streams = &streamTable{m: make(map[string]*stream)}
+
+func (t *streamTable) get(id string) (*stream, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.m[id]
+	return s, ok
+}
+
+func (t *streamTable) put(id string, s *stream) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.m[id] = s
+}
+
+// remove takes the stream out of the table, only one of concurrent callers gets it
+func (t *streamTable) remove(id string) (*stream, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.m[id]
+	delete(t.m, id)
+	return s, ok
+}
 
 func New(ctx context.Context) *Client {
-	return &Client{
-		ctx: ctx,
+	c := &Client{
+		ctx:         ctx,
+		pool:        newConnPool(defaultIdleTimeout),
+		history:     newHistory(maxHistory),
+		httpClients: make(map[string]*http.Client),
 	}
+	go c.pool.run(ctx)
+	return c
+}
+
+func (c *Client) Conns() []ConnState {
+	return c.pool.states()
+}
+
+// Reconnect dials the pooled connection of key, see ConnState.Key, again
+func (c *Client) Reconnect(key string) error {
+	return c.pool.reconnect(key)
+}
+
+func (c *Client) History() []Call {
+	return c.history.list()
 }
 
 func (c *Client) Send(req *RequestData) {
 	logrus.Debugf("send req: %v", req)
+	if err := checkFormats(req); err != nil {
+		emitErr(c.ctx, req.Id, nil, err, nil)
+		emitClose(c.ctx, req.Id)
+		return
+	}
+	switch req.Transport {
+	case TransportGrpcWeb, TransportGrpcWebText:
+		c.invokeWeb(req)
+		return
+	case TransportConnect:
+		c.invokeConnect(req)
+		return
+	case TransportTwirp:
+		c.invokeTwirp(req)
+		return
+	case TransportHTTP:
+		c.invokeHTTP(req)
+		return
+	}
+
 	switch req.MethodMode {
 	case Unary:
 		c.invokeUnary(req)
@@ -91,203 +195,300 @@ func (c *Client) Send(req *RequestData) {
 }
 
 func (c *Client) Push(req *RequestData) {
-	if stream, ok := streams[req.Id]; ok {
+	if stream, ok := streams.get(req.Id); ok {
 		methodDesc, _ := findMethodDesc(req.ProtoPath, req.IncludeDirs, req.ServiceFullyName, req.MethodName)
+		msg, err := buildRequest(methodDesc, req)
+		if err != nil {
+			// the stream stays open for a corrected message
+			emitErr(c.ctx, req.Id, nil, err, nil)
+			return
+		}
+		if stream.body != nil {
+			c.writeConnect(stream, msg)
+			return
+		}
 		if req.MethodMode == ClientStream {
-			stream.cliStream.SendMsg(buildRequest(methodDesc, req.Body))
+			stream.cliStream.SendMsg(msg)
 		}
 		if req.MethodMode == BidirectionalStream {
-			stream.bidiStream.SendMsg(buildRequest(methodDesc, req.Body))
+			stream.bidiStream.SendMsg(msg)
 		}
 	}
 }
 
 func (c *Client) Stop(id string) {
-	stream, ok := streams[id]
+	stream, ok := streams.get(id)
 	if !ok {
 		return
 	}
-	if stream.methodMode == ClientStream {
-		if msg, err := stream.cliStream.CloseAndReceive(); err == nil {
-			emitMsg(c.ctx, id, parseResponse(stream.methodDesc, &msg), parsePairs(stream.cliStream.Trailer()))
+	if stream.body != nil && !stream.sendClosed && stream.methodMode != ServerStream {
+		// end the request stream, the response is closed once the server finishes
+		stream.closeSend()
+		return
+	}
+	if stream.cliStream != nil {
+		msg, err := stream.cliStream.CloseAndReceive()
+		if err == nil {
+			emitMsg(c.ctx, id, parseResponse(stream.methodDesc, &msg, stream.decoder, stream.req), parsePairs(stream.cliStream.Trailer()), stream.trace.timing())
 		}
+		stream.err = err
 	}
 
-	if stream.methodMode == BidirectionalStream {
+	if stream.bidiStream != nil {
 		_ = stream.bidiStream.CloseSend()
 	}
 
-	close(c.ctx, id)
+	c.close(id)
 }
 
-func close(ctx context.Context, id string) {
-	emitClose(ctx, id)
-	if stream, ok := streams[id]; ok {
-		stream.cli.close()
-		delete(streams, id)
-	}
+func (s *stream) closeSend() {
+	s.sendClosed = true
+	_ = s.body.Close()
 }
 
-func findMethodDesc(protoPath string, includeDirs []string, serviceFullyName string, methodName string) (*desc.MethodDescriptor, error) {
-	//  parse proto
-	p := protoparse.Parser{}
-	p.Accessor = func(filename string) (io.ReadCloser, error) {
-		fmt.Printf("Accessor filename: %v \n", filename)
-		lookupFile := lookupFile(filename, append(includeDirs, path.Dir(protoPath)))
-		return os.OpenFile(lookupFile, syscall.O_RDONLY, 0)
-	}
-	protoDesc, err := p.ParseFiles(protoPath)
-	if err != nil {
-		return nil, err
+func (c *Client) close(id string) {
+	emitClose(c.ctx, id)
+	if stream, ok := streams.remove(id); ok {
+		if stream.cancel != nil {
+			stream.cancel()
+		}
+		if stream.cli != nil {
+			stream.cli.close()
+		}
+		c.history.add(stream.req, stream.trace.timing(), stream.err)
 	}
-	return protoDesc[0].FindService(serviceFullyName).FindMethodByName(methodName), nil
 }
 
-func lookupFile(fileName string, includeDirs []string) string {
-	for _, dir := range includeDirs {
-		joinFile := path.Join(dir, fileName)
-		if ok, _ := file.ExistPath(joinFile); ok {
-			return joinFile
+// findMethodDesc looks the method up in the registry of parsed services. A given protoPath
+// is parsed again first, so edits of the source are picked up.
+func findMethodDesc(protoPath string, includeDirs []string, serviceFullyName string, methodName string) (*desc.MethodDescriptor, error) {
+	if protoPath != "" {
+		if err := uproto.Load(protoPath, includeDirs); err != nil && uproto.FindService(serviceFullyName) == nil {
+			return nil, err
 		}
 	}
-	return fileName
+	serviceDesc := uproto.FindService(serviceFullyName)
+	if serviceDesc == nil {
+		return nil, errors.Errorf("service %s not found, parse a file declaring it first", serviceFullyName)
+	}
+	methodDesc := serviceDesc.FindMethodByName(methodName)
+	if methodDesc == nil {
+		return nil, errors.Errorf("method %s not found in service %s", methodName, serviceFullyName)
+	}
+	return methodDesc, nil
 }
 
-func buildContext(mds *[]Metadata) context.Context {
+func buildContext(mds *[]Metadata, t *trace) context.Context {
 	md := buildPairs(*mds)
-	return metadata.NewOutgoingContext(context.Background(), md)
+	return metadata.NewOutgoingContext(withTrace(context.Background(), t), md)
 }
 
-func buildRequest(methodDesc *desc.MethodDescriptor, body string) *dynamic.Message {
+func buildRequest(methodDesc *desc.MethodDescriptor, req *RequestData) (*dynamic.Message, error) {
 	reqMsg := dynamic.NewMessage(methodDesc.GetInputType())
-	reqMsg.UnmarshalMergeJSON([]byte(body))
-	return reqMsg
+	if err := decodeBody(reqMsg, req.Body, req.Format); err != nil {
+		return nil, err
+	}
+	return reqMsg, nil
 }
 
-func parseResponse(methodDesc *desc.MethodDescriptor, response *proto.Message) string {
+// parseResponse renders a response of the grpc transport, decoder holds the bytes of
+// responses that did not match the output type
+func parseResponse(methodDesc *desc.MethodDescriptor, response *proto.Message, decoder *fallbackCodec, req *RequestData) string {
+	if raw, err := decoder.undecoded(); err != nil {
+		body, _ := renderRaw(raw, undecodedErr(methodDesc, err), req)
+		return body
+	}
 	respMsg := dynamic.NewMessage(methodDesc.GetOutputType())
 	respMsg.ConvertFrom(*response)
-	byte, _ := respMsg.MarshalJSONIndent()
-	return string(byte)
+	body, _ := renderMessage(respMsg, req)
+	return body
+}
+
+func parseResponseBytes(methodDesc *desc.MethodDescriptor, b []byte, req *RequestData) (string, error) {
+	respMsg := dynamic.NewMessage(methodDesc.GetOutputType())
+	err := respMsg.Unmarshal(b)
+	if err == nil {
+		err = mismatch(respMsg)
+	}
+	if err != nil {
+		return renderRaw(b, undecodedErr(methodDesc, err), req)
+	}
+	return renderMessage(respMsg, req)
+}
+
+func undecodedErr(methodDesc *desc.MethodDescriptor, err error) error {
+	return errors.Wrapf(err, "response is not a valid %s", methodDesc.GetOutputType().GetFullyQualifiedName())
+}
+
+// fail reports err for req, streaming calls are closed afterwards
+func (c *Client) fail(req *RequestData, mds []Metadata, tr *trace, err error) {
+	emitErr(c.ctx, req.Id, mds, err, tr.timing())
+	if req.MethodMode == Unary {
+		if tr != nil {
+			c.history.add(req, tr.timing(), err)
+		}
+		return
+	}
+	if stream, ok := streams.get(req.Id); ok {
+		stream.err = err
+	}
+	c.close(req.Id)
 }
 
 func (c *Client) invokeUnary(req *RequestData) {
-	cliStub, err := createStub(req.Host)
+	cliStub, err := c.createStub(req)
 	if err != nil {
-		emitErr(c.ctx, req.Id, nil, err)
+		emitErr(c.ctx, req.Id, nil, err, nil)
 		emitClose(c.ctx, req.Id)
 		return
 	}
 
 	methodDesc, err := findMethodDesc(req.ProtoPath, req.IncludeDirs, req.ServiceFullyName, req.MethodName)
 	if err != nil {
-		emitErr(c.ctx, req.Id, nil, err)
+		emitErr(c.ctx, req.Id, nil, err, nil)
 		cliStub.close()
 		return
 	}
 
 	var trailer metadata.MD
-	resp, err := cliStub.stub.InvokeRpc(buildContext(&req.Mds), methodDesc, buildRequest(methodDesc, req.Body), grpc.Trailer(&trailer))
+	tr := cliStub.newTrace()
+	reqMsg, err := buildRequest(methodDesc, req)
 	if err != nil {
-		emitErr(c.ctx, req.Id, parsePairs(trailer), err)
+		emitErr(c.ctx, req.Id, nil, err, nil)
 		cliStub.close()
 		return
 	}
-
-	emitMsg(c.ctx, req.Id, parseResponse(methodDesc, &resp), nil)
+	decoder := &fallbackCodec{}
+	resp, err := cliStub.stub.InvokeRpc(buildContext(&req.Mds, tr), methodDesc, reqMsg, grpc.Trailer(&trailer), grpc.ForceCodec(decoder))
 	cliStub.close()
+	c.history.add(req, tr.timing(), err)
+	if err != nil {
+		emitErr(c.ctx, req.Id, parsePairs(trailer), err, tr.timing())
+		return
+	}
+
+	emitMsg(c.ctx, req.Id, parseResponse(methodDesc, &resp, decoder, req), nil, tr.timing())
 }
 
 func (c *Client) invokeClientStream(req *RequestData) {
-	cliStub, err := createStub(req.Host)
+	cliStub, err := c.createStub(req)
 	if err != nil {
-		emitErr(c.ctx, req.Id, nil, err)
-		close(c.ctx, req.Id)
+		emitErr(c.ctx, req.Id, nil, err, nil)
+		c.close(req.Id)
 		return
 	}
 
 	methodDesc, err := findMethodDesc(req.ProtoPath, req.IncludeDirs, req.ServiceFullyName, req.MethodName)
 	if err != nil {
-		emitErr(c.ctx, req.Id, nil, err)
-		close(c.ctx, req.Id)
+		emitErr(c.ctx, req.Id, nil, err, nil)
+		cliStub.close()
+		c.close(req.Id)
 		return
 	}
 
-	clientStream, err := cliStub.stub.InvokeRpcClientStream(buildContext(&req.Mds), methodDesc)
+	tr := cliStub.newTrace()
+	decoder := &fallbackCodec{}
+	clientStream, err := cliStub.stub.InvokeRpcClientStream(buildContext(&req.Mds, tr), methodDesc, grpc.ForceCodec(decoder))
 	if err != nil {
-		emitErr(c.ctx, req.Id, nil, err)
-		close(c.ctx, req.Id)
+		emitErr(c.ctx, req.Id, nil, err, nil)
+		cliStub.close()
+		c.close(req.Id)
 		return
 	}
 
-	streams[req.Id] = &stream{
+	streams.put(req.Id, &stream{
+		req:        req,
+		trace:      tr,
 		methodMode: req.MethodMode,
 		methodDesc: methodDesc,
+		decoder:    decoder,
 		cli:        cliStub,
 		cliStream:  clientStream,
-	}
+	})
 }
 
 func (c *Client) invokeServerStream(req *RequestData) {
-	cliStub, err := createStub(req.Host)
+	cliStub, err := c.createStub(req)
 	if err != nil {
-		emitErr(c.ctx, req.Id, nil, err)
-		close(c.ctx, req.Id)
+		emitErr(c.ctx, req.Id, nil, err, nil)
+		c.close(req.Id)
 		return
 	}
 
 	methodDesc, err := findMethodDesc(req.ProtoPath, req.IncludeDirs, req.ServiceFullyName, req.MethodName)
 	if err != nil {
-		emitErr(c.ctx, req.Id, nil, err)
-		close(c.ctx, req.Id)
+		emitErr(c.ctx, req.Id, nil, err, nil)
+		cliStub.close()
+		c.close(req.Id)
 		return
 	}
 
-	srvStream, err := cliStub.stub.InvokeRpcServerStream(buildContext(&req.Mds), methodDesc, buildRequest(methodDesc, req.Body))
+	tr := cliStub.newTrace()
+	reqMsg, err := buildRequest(methodDesc, req)
 	if err != nil {
-		emitErr(c.ctx, req.Id, nil, err)
-		close(c.ctx, req.Id)
+		emitErr(c.ctx, req.Id, nil, err, nil)
+		cliStub.close()
+		c.close(req.Id)
+		return
+	}
+	decoder := &fallbackCodec{}
+	srvStream, err := cliStub.stub.InvokeRpcServerStream(buildContext(&req.Mds, tr), methodDesc, reqMsg, grpc.ForceCodec(decoder))
+	if err != nil {
+		emitErr(c.ctx, req.Id, nil, err, nil)
+		cliStub.close()
+		c.close(req.Id)
 		return
 	}
 
-	streams[req.Id] = &stream{
+	st := &stream{
+		req:        req,
+		trace:      tr,
 		methodMode: req.MethodMode,
 		methodDesc: methodDesc,
+		decoder:    decoder,
 		cli:        cliStub,
 		srvStream:  srvStream,
 	}
-
-	go c.readStream(streams[req.Id], srvStream, req.Id)
+	streams.put(req.Id, st)
+	go c.readStream(st, srvStream, req.Id)
 }
 
 func (c *Client) invokeBidiStream(req *RequestData) {
-	cliStub, err := createStub(req.Host)
+	cliStub, err := c.createStub(req)
 	if err != nil {
-		emitErr(c.ctx, req.Id, nil, err)
+		emitErr(c.ctx, req.Id, nil, err, nil)
 		emitClose(c.ctx, req.Id)
 		return
 	}
 
 	methodDesc, err := findMethodDesc(req.ProtoPath, req.IncludeDirs, req.ServiceFullyName, req.MethodName)
 	if err != nil {
-		emitErr(c.ctx, req.Id, nil, err)
-		close(c.ctx, req.Id)
+		emitErr(c.ctx, req.Id, nil, err, nil)
+		cliStub.close()
+		c.close(req.Id)
 		return
 	}
 
-	bidiStream, err := cliStub.stub.InvokeRpcBidiStream(buildContext(&req.Mds), methodDesc)
+	tr := cliStub.newTrace()
+	decoder := &fallbackCodec{}
+	bidiStream, err := cliStub.stub.InvokeRpcBidiStream(buildContext(&req.Mds, tr), methodDesc, grpc.ForceCodec(decoder))
 	if err != nil {
-		emitErr(c.ctx, req.Id, nil, err)
-		close(c.ctx, req.Id)
+		emitErr(c.ctx, req.Id, nil, err, nil)
+		cliStub.close()
+		c.close(req.Id)
 		return
 	}
-	streams[req.Id] = &stream{
+	st := &stream{
+		req:        req,
+		trace:      tr,
 		methodMode: req.MethodMode,
 		methodDesc: methodDesc,
+		decoder:    decoder,
 		cli:        cliStub,
 		bidiStream: bidiStream,
 	}
-	go c.readStream(streams[req.Id], bidiStream, req.Id)
+	streams.put(req.Id, st)
+	go c.readStream(st, bidiStream, req.Id)
 	c.Push(req)
 }
 
@@ -302,20 +503,19 @@ func (c *Client) readStream(stream *stream, readStream recvStream, id string) {
 		respMsg.Reset()
 		// block until response is received
 		msg, err := readStream.RecvMsg()
-
-		fmt.Printf("srever stream: %v, error: %v\n", msg, err)
 		if err == nil {
-			emitMsg(c.ctx, id, parseResponse(stream.methodDesc, &msg), nil)
+			emitMsg(c.ctx, id, parseResponse(stream.methodDesc, &msg, stream.decoder, stream.req), nil, stream.trace.timing())
 			continue
 		}
 
 		if err == io.EOF {
-			emitMsg(c.ctx, id, parseResponse(stream.methodDesc, &msg), parsePairs(readStream.Trailer()))
-			close(c.ctx, id)
+			emitMsg(c.ctx, id, parseResponse(stream.methodDesc, &msg, stream.decoder, stream.req), parsePairs(readStream.Trailer()), stream.trace.timing())
+			c.close(id)
 			break
 		}
-		emitErr(c.ctx, id, nil, err)
-		close(c.ctx, id)
+		stream.err = err
+		emitErr(c.ctx, id, nil, err, stream.trace.timing())
+		c.close(id)
 		break
 	}
 }
@@ -0,0 +1,282 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	uproto "uprpc/proto"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const (
+	connectCompressedFlag byte = 0x01
+	connectEndStreamFlag  byte = 0x02
+)
+
+type connectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"details"`
+}
+
+func (e *connectError) err() error {
+	s := &spb.Status{Code: int32(codeOfName(e.Code)), Message: e.Message}
+	for _, detail := range e.Details {
+		if value, err := decodeBinHeader(detail.Value); err == nil {
+			s.Details = append(s.Details, &anypb.Any{TypeUrl: "type.googleapis.com/" + detail.Type, Value: value})
+		}
+	}
+	return status.FromProto(s).Err()
+}
+
+type connectEndStream struct {
+	Error    *connectError       `json:"error"`
+	Metadata map[string][]string `json:"metadata"`
+}
+
+// connectHTTPError returns the error of a response with a status other than 200,
+// Connect describes it as a json error in the body of unary and streaming responses
+func connectHTTPError(resp *http.Response, body []byte) error {
+	var connectErr connectError
+	if json.Unmarshal(body, &connectErr) == nil && connectErr.Code != "" {
+		return connectErr.err()
+	}
+	return status.Error(httpStatusCode(resp.StatusCode), resp.Status)
+}
+
+func (c *Client) invokeConnect(req *RequestData) {
+	methodDesc, err := findMethodDesc(req.ProtoPath, req.IncludeDirs, req.ServiceFullyName, req.MethodName)
+	if err != nil {
+		c.fail(req, nil, nil, err)
+		return
+	}
+
+	codec := codecOf(req)
+	path := "/" + req.ServiceFullyName + "/" + req.MethodName
+	ctx, cancel := context.WithCancel(context.Background())
+	tr := newHTTPTrace()
+
+	if req.MethodMode == Unary {
+		defer cancel()
+		msg, err := buildRequest(methodDesc, req)
+		if err != nil {
+			c.fail(req, nil, nil, err)
+			return
+		}
+		body, err := marshalCodec(msg, codec)
+		if err != nil {
+			c.fail(req, nil, nil, err)
+			return
+		}
+		httpCli, httpReq, err := c.newHTTPRequest(ctx, req, path, bytes.NewReader(body), tr)
+		if err != nil {
+			c.fail(req, nil, tr, err)
+			return
+		}
+		httpReq.Header.Set("Content-Type", "application/"+codec)
+		httpReq.Header.Set("Connect-Protocol-Version", "1")
+		resp, err := httpCli.Do(httpReq)
+		if err != nil {
+			c.fail(req, nil, tr, err)
+			return
+		}
+		c.readConnectUnary(req, methodDesc, codec, resp, tr)
+		return
+	}
+
+	// streaming requests are written as enveloped messages into the request body
+	reader, writer := io.Pipe()
+	httpCli, httpReq, err := c.newHTTPRequest(ctx, req, path, reader, tr)
+	if err != nil {
+		cancel()
+		c.fail(req, nil, tr, err)
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/connect+"+codec)
+	httpReq.Header.Set("Connect-Protocol-Version", "1")
+
+	stream := &stream{
+		req:        req,
+		trace:      tr,
+		methodMode: req.MethodMode,
+		methodDesc: methodDesc,
+		cancel:     cancel,
+		body:       writer,
+		codec:      codec,
+	}
+	streams.put(req.Id, stream)
+	go c.readConnectStream(stream, httpCli, httpReq)
+
+	switch req.MethodMode {
+	case ServerStream:
+		msg, err := buildRequest(methodDesc, req)
+		if err != nil {
+			c.fail(req, nil, tr, err)
+			return
+		}
+		c.writeConnect(stream, msg)
+		stream.closeSend()
+	case BidirectionalStream:
+		c.Push(req)
+	}
+}
+
+func (c *Client) readConnectUnary(req *RequestData, methodDesc *desc.MethodDescriptor, codec string, resp *http.Response, tr *trace) {
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.fail(req, nil, tr, err)
+		return
+	}
+	tr.markMessage(time.Now())
+	tr.markEnd(time.Now())
+
+	// unary trailers are sent as headers prefixed with Trailer-
+	header, trailer := http.Header{}, http.Header{}
+	for key, values := range resp.Header {
+		if strings.HasPrefix(strings.ToLower(key), "trailer-") {
+			trailer[key[len("trailer-"):]] = values
+		} else {
+			header[key] = values
+		}
+	}
+	mds := append(parseHeaders(header, reservedHeaders...), parseHeaders(trailer)...)
+
+	if resp.StatusCode != http.StatusOK {
+		c.fail(req, mds, tr, connectHTTPError(resp, b))
+		return
+	}
+
+	body, err := parseResponseCodec(methodDesc, codec, b, req)
+	if err != nil {
+		c.fail(req, mds, tr, err)
+		return
+	}
+	c.history.add(req, tr.timing(), nil)
+	emitMsg(c.ctx, req.Id, body, mds, tr.timing())
+}
+
+func (c *Client) readConnectStream(stream *stream, httpCli *http.Client, httpReq *http.Request) {
+	req := stream.req
+	resp, err := httpCli.Do(httpReq)
+	if err != nil {
+		if _, ok := streams.get(req.Id); ok {
+			c.fail(req, nil, stream.trace, err)
+		}
+		return
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+	// initial metadata goes with the first message, the end-stream metadata with the end
+	header := parseHeaders(resp.Header, reservedHeaders...)
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		c.fail(req, header, stream.trace, connectHTTPError(resp, b))
+		return
+	}
+
+	end, err := readConnectFrames(resp.Body, func(payload []byte) error {
+		stream.trace.markMessage(time.Now())
+		body, err := parseResponseCodec(stream.methodDesc, stream.codec, payload, req)
+		if err != nil {
+			return err
+		}
+		emitMsg(c.ctx, req.Id, body, header, stream.trace.timing())
+		header = nil
+		return nil
+	})
+	if err != nil {
+		if _, ok := streams.get(req.Id); ok {
+			c.fail(req, header, stream.trace, err)
+		}
+		return
+	}
+	stream.trace.markEnd(time.Now())
+	mds := append(header, parseHeaders(end.Metadata)...)
+	if end.Error != nil {
+		c.fail(req, mds, stream.trace, end.Error.err())
+		return
+	}
+	body, _ := parseResponseBytes(stream.methodDesc, nil, req)
+	emitMsg(c.ctx, req.Id, body, mds, stream.trace.timing())
+	c.close(req.Id)
+}
+
+// readConnectFrames hands the payload of every message frame of body to onMessage
+// and returns the end-stream message
+func readConnectFrames(body io.Reader, onMessage func(payload []byte) error) (*connectEndStream, error) {
+	for {
+		flag, payload, err := readFrame(body)
+		if err == io.EOF {
+			return nil, status.Error(codes.Internal, "server closed the stream without end-stream message")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if flag&connectCompressedFlag != 0 {
+			return nil, errors.New("compressed connect messages are not supported")
+		}
+
+		if flag&connectEndStreamFlag != 0 {
+			var end connectEndStream
+			if err := json.Unmarshal(payload, &end); err != nil {
+				return nil, errors.Wrap(err, "malformed end-stream message")
+			}
+			return &end, nil
+		}
+		if err := onMessage(payload); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) writeConnect(stream *stream, msg *dynamic.Message) {
+	b, err := marshalCodec(msg, stream.codec)
+	if err == nil {
+		_, err = stream.body.Write(encodeFrame(0, b))
+	}
+	if err != nil {
+		emitErr(c.ctx, stream.req.Id, nil, err, nil)
+	}
+}
+
+func codecOf(req *RequestData) string {
+	if req.Codec == CodecProto {
+		return CodecProto
+	}
+	return CodecJSON
+}
+
+func marshalCodec(msg *dynamic.Message, codec string) ([]byte, error) {
+	if codec == CodecProto {
+		return msg.Marshal()
+	}
+	return uproto.MarshalJSON(msg)
+}
+
+func parseResponseCodec(methodDesc *desc.MethodDescriptor, codec string, b []byte, req *RequestData) (string, error) {
+	if codec == CodecProto {
+		return parseResponseBytes(methodDesc, b, req)
+	}
+	respMsg := dynamic.NewMessage(methodDesc.GetOutputType())
+	if err := uproto.UnmarshalJSON(respMsg, b); err != nil {
+		return "", errors.Wrap(err, "decode response error")
+	}
+	return renderMessage(respMsg, req)
+}
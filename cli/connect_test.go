@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestReadConnectFrames(t *testing.T) {
+	end := encodeFrame(connectEndStreamFlag, []byte(`{"error":{"code":"aborted","message":"stop"},"metadata":{"x-trailer":["b"]}}`))
+	cases := []struct {
+		name     string
+		frames   [][]byte
+		messages int
+		code     codes.Code
+		err      bool
+	}{
+		{name: "end with error", frames: [][]byte{encodeFrame(0, []byte("first")), encodeFrame(0, []byte("second")), end}, messages: 2, code: codes.Aborted},
+		{name: "end without error", frames: [][]byte{encodeFrame(0, []byte("first")), encodeFrame(connectEndStreamFlag, []byte(`{}`))}, messages: 1},
+		{name: "missing end", frames: [][]byte{encodeFrame(0, []byte("first"))}, messages: 1, err: true},
+		{name: "compressed", frames: [][]byte{encodeFrame(connectCompressedFlag, []byte("x"))}, err: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var messages []string
+			got, err := readConnectFrames(bytes.NewReader(bytes.Join(c.frames, nil)), func(payload []byte) error {
+				messages = append(messages, string(payload))
+				return nil
+			})
+			if len(messages) != c.messages || (c.messages > 0 && messages[0] != "first") {
+				t.Fatalf("messages: %q", messages)
+			}
+			if (err != nil) != c.err {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if (got.Error != nil) != (c.code != codes.OK) {
+				t.Fatalf("unexpected end-stream error %+v", got.Error)
+			}
+			if got.Error == nil {
+				return
+			}
+			if code := status.Code(got.Error.err()); code != c.code || got.Metadata["x-trailer"][0] != "b" {
+				t.Fatalf("want %v with trailer metadata, got %v %v", c.code, code, got.Metadata)
+			}
+		})
+	}
+}
+
+func TestConnectJSONCodec(t *testing.T) {
+	parser := protoparse.Parser{Accessor: protoparse.FileContentsFromMap(map[string]string{
+		"patch.proto": `syntax = "proto3"; package patch; import "google/protobuf/field_mask.proto";
+message Patch { string name = 1; google.protobuf.FieldMask update_mask = 2; }
+service Patcher { rpc Apply(Patch) returns (Patch); }`,
+	})}
+	fds, err := parser.ParseFiles("patch.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	method := fds[0].FindService("patch.Patcher").FindMethodByName("Apply")
+	msg := dynamic.NewMessage(method.GetInputType())
+	if err := decodeBody(msg, `{"name":"a","updateMask":"name,updateMask"}`, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	// protojson servers only accept the canonical forms
+	b, err := marshalCodec(msg, CodecJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"updateMask":"name,updateMask"`) {
+		t.Fatalf("field mask should be a path string: %s", b)
+	}
+	body, err := parseResponseCodec(method, CodecJSON, b, &RequestData{JsonOptions: &JsonOptions{Compact: true}})
+	if err != nil || !strings.Contains(body, `"paths":["name","update_mask"]`) {
+		t.Fatalf("response does not decode: %s %v", body, err)
+	}
+}
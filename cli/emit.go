@@ -7,21 +7,24 @@ import (
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
-func emitMsg(ctx context.Context, id string, message string, mds []Metadata) {
+func emitMsg(ctx context.Context, id string, message string, mds []Metadata, timing *Timing) {
 	respData := ResponseData{
-		Id:   id,
-		Body: message,
-		Mds:  mds,
+		Id:     id,
+		Body:   message,
+		Mds:    mds,
+		Timing: timing,
 	}
 	log.Printf("return data:%v", respData)
 	runtime.EventsEmit(ctx, "data", respData)
 }
 
-func emitErr(ctx context.Context, id string, mds []Metadata, err error) {
+func emitErr(ctx context.Context, id string, mds []Metadata, err error, timing *Timing) {
 	respData := ResponseData{
-		Id:   id,
-		Body: err.Error(),
-		Mds:  mds,
+		Id:     id,
+		Body:   err.Error(),
+		Mds:    mds,
+		Status: toStatus(err),
+		Timing: timing,
 	}
 	runtime.EventsEmit(ctx, "data", respData)
 }
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	uproto "uprpc/proto"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+)
+
+// fallbackCodec is the proto codec of grpc, except that responses not matching the output
+// type are kept instead of failing the call so they can be shown without the schema.
+// The failure of the last response read is remembered, reads of a call are sequential.
+type fallbackCodec struct {
+	raw []byte
+	err error
+}
+
+func (c *fallbackCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, errors.Errorf("failed to marshal, message is %T, want proto.Message", v)
+	}
+	return proto.Marshal(msg)
+}
+
+func (c *fallbackCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return errors.Errorf("failed to unmarshal, message is %T, want proto.Message", v)
+	}
+	c.raw, c.err = nil, nil
+	err := proto.Unmarshal(data, msg)
+	if err == nil {
+		err = mismatch(msg)
+	}
+	if err != nil {
+		// data is reused by grpc once Unmarshal returns
+		c.raw, c.err = append([]byte{}, data...), err
+	}
+	return nil
+}
+
+// mismatch reports a decoded response with fields its type does not declare, a message
+// of another type mostly decodes without error but into unknown fields
+func mismatch(msg proto.Message) error {
+	dm, ok := msg.(*dynamic.Message)
+	if !ok {
+		return nil
+	}
+	if unknown := unknownFields(dm, ""); len(unknown) > 0 {
+		return errors.Errorf("undeclared fields %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func (c *fallbackCodec) Name() string {
+	return "proto"
+}
+
+// undecoded returns the bytes of the last response if it did not match the output type.
+// The failure is forgotten, so the end of a stream does not report it again.
+func (c *fallbackCodec) undecoded() ([]byte, error) {
+	if c == nil || c.err == nil {
+		return nil, nil
+	}
+	raw, err := c.raw, c.err
+	c.raw, c.err = nil, nil
+	return raw, err
+}
+
+// rawView is a response rendered without its schema
+type rawView struct {
+	Undecoded string              `json:"undecoded"`
+	Fields    []*uproto.WireField `json:"fields,omitempty"`
+	Hex       string              `json:"hex,omitempty"`
+}
+
+// renderRaw shows response bytes that could not be decoded as the output type, as they
+// are for the binary formats and as their schema-less wire fields otherwise
+func renderRaw(b []byte, cause error, req *RequestData) (string, error) {
+	switch req.ResponseFormat {
+	case FormatBase64:
+		return base64.StdEncoding.EncodeToString(b), nil
+	case FormatHex:
+		return hex.Dump(b), nil
+	}
+	view := &rawView{Undecoded: cause.Error()}
+	fields, err := uproto.DecodeWire(b, nil)
+	if err != nil {
+		view.Hex = hex.EncodeToString(b)
+	} else {
+		view.Fields = fields
+	}
+	out, err := json.MarshalIndent(view, "", "  ")
+	return string(out), err
+}
@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	uproto "uprpc/proto"
+
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// formats of request bodies and rendered responses, json when empty
+const (
+	FormatJSON = "json"
+	FormatText = "prototext"
+	FormatYAML = "yaml"
+	// FormatBase64 and FormatHex are wire-format bytes, FormatFile is the path of a file
+	// holding them and only used for bodies
+	FormatBase64 = "base64"
+	FormatHex    = "hex"
+	FormatFile   = "file"
+	// FormatWire renders responses as the fields found in their encoding
+	FormatWire = "wire"
+)
+
+// decodeBody merges a request body written in format into msg. YAML follows the json
+// mapping of proto3, so bytes are base64 and well-known types have their json forms.
+func decodeBody(msg *dynamic.Message, body, format string) error {
+	if strings.TrimSpace(body) == "" {
+		return nil
+	}
+	switch format {
+	case "", FormatJSON:
+		return unmarshalBodyJSON(msg, []byte(uproto.StripComments(body)))
+	case FormatText:
+		if err := msg.UnmarshalMergeText([]byte(body)); err != nil {
+			return errors.Wrap(err, "request body is not valid prototext")
+		}
+		return nil
+	case FormatYAML:
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(body), &value); err != nil {
+			return errors.Wrap(err, "request body is not valid yaml")
+		}
+		b, err := json.Marshal(jsonValue(value))
+		if err != nil {
+			return errors.Wrap(err, "request body is not valid yaml")
+		}
+		return unmarshalBodyJSON(msg, b)
+	case FormatBase64, FormatHex, FormatFile:
+		return decodeBinaryBody(msg, body, format)
+	default:
+		return errors.Errorf("unknown body format %s", format)
+	}
+}
+
+func unmarshalBodyJSON(msg *dynamic.Message, b []byte) error {
+	if err := uproto.UnmarshalJSON(msg, b); err != nil {
+		return errors.Wrap(err, "decode request body error")
+	}
+	return nil
+}
+
+// jsonValue turns the maps yaml decodes with non-string keys into json objects
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = jsonValue(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			object[fmt.Sprint(k)] = jsonValue(item)
+		}
+		return object
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// renderMessage writes a response in the response format of req
+func renderMessage(msg *dynamic.Message, req *RequestData) (string, error) {
+	switch req.ResponseFormat {
+	case "", FormatJSON:
+		b, err := marshalJSON(msg, req.JsonOptions)
+		return string(b), err
+	case FormatText:
+		b, err := msg.MarshalTextIndent()
+		return string(b), err
+	case FormatYAML:
+		// compact is meaningless in yaml, the other options apply
+		b, err := marshalJSON(msg, req.JsonOptions)
+		if err != nil {
+			return "", err
+		}
+		return jsonToYAML(b)
+	case FormatBase64:
+		b, err := msg.Marshal()
+		return base64.StdEncoding.EncodeToString(b), err
+	case FormatHex:
+		b, err := msg.Marshal()
+		return hex.Dump(b), err
+	case FormatWire:
+		b, err := msg.Marshal()
+		if err != nil {
+			return "", err
+		}
+		fields, err := uproto.DecodeWire(b, msg.GetMessageDescriptor())
+		if err != nil {
+			return "", err
+		}
+		view, err := json.MarshalIndent(fields, "", "  ")
+		return string(view), err
+	default:
+		return "", errors.Errorf("unknown response format %s", req.ResponseFormat)
+	}
+}
+
+// jsonToYAML keeps the field order and quotes the strings yaml would read as other types
+func jsonToYAML(b []byte) (string, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return "", err
+	}
+	blockStyle(&node)
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+func checkFormats(req *RequestData) error {
+	switch req.Format {
+	case "", FormatJSON, FormatText, FormatYAML, FormatBase64, FormatHex, FormatFile:
+	default:
+		return errors.Errorf("unknown body format %s", req.Format)
+	}
+	switch req.ResponseFormat {
+	case "", FormatJSON, FormatText, FormatYAML, FormatBase64, FormatHex, FormatWire:
+	default:
+		return errors.Errorf("unknown response format %s", req.ResponseFormat)
+	}
+	return nil
+}
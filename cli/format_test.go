@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+func TestBodyFormats(t *testing.T) {
+	parser := protoparse.Parser{Accessor: protoparse.FileContentsFromMap(map[string]string{
+		"order.proto": `syntax = "proto3"; package order;
+message Order { string id = 1; int64 total = 2; bytes note = 3; map<int32, string> lines = 4; repeated string tags = 5; }`,
+	})}
+	fds, err := parser.ParseFiles("order.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := fds[0].FindMessage("order.Order")
+
+	bodies := map[string]string{
+		FormatJSON: `{"id": "true", "total": "42", "note": "aGk=", "lines": {"1": "a"}, "tags": ["x"]}`,
+		FormatText: `id: "true" total: 42 note: "hi" lines { key: 1 value: "a" } tags: "x"`,
+		FormatYAML: "id: \"true\"\ntotal: 42\nnote: aGk=\nlines:\n  1: a\ntags: [x]\n",
+	}
+	var want *dynamic.Message
+	for format, body := range bodies {
+		msg := dynamic.NewMessage(md)
+		if err := decodeBody(msg, body, format); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if want == nil {
+			want = msg
+		} else if !dynamic.Equal(want, msg) {
+			t.Fatalf("%s body decoded to %v, want %v", format, msg, want)
+		}
+
+		// rendered responses read back in the same format
+		rendered, err := renderMessage(msg, &RequestData{ResponseFormat: format})
+		if err != nil {
+			t.Fatal(err)
+		}
+		again := dynamic.NewMessage(md)
+		if err := decodeBody(again, rendered, format); err != nil || !dynamic.Equal(msg, again) {
+			t.Fatalf("%s response %q does not round-trip: %v", format, rendered, err)
+		}
+	}
+
+	if err := decodeBody(dynamic.NewMessage(md), "id: [", FormatYAML); err == nil {
+		t.Fatal("invalid yaml should fail")
+	}
+
+	b, _ := want.Marshal()
+	for format, body := range map[string]string{
+		FormatBase64: base64.StdEncoding.EncodeToString(b),
+		FormatHex:    hex.EncodeToString(b),
+	} {
+		msg := dynamic.NewMessage(md)
+		if err := decodeBody(msg, body, format); err != nil || !dynamic.Equal(want, msg) {
+			t.Fatalf("%s body decoded to %v: %v", format, msg, err)
+		}
+	}
+	// field 9 is not declared by order.Order
+	if err := decodeBody(dynamic.NewMessage(md), "4801", FormatHex); err == nil {
+		t.Fatal("bytes of another type should fail")
+	}
+}
+
+func TestUndecodedResponse(t *testing.T) {
+	parser := protoparse.Parser{Accessor: protoparse.FileContentsFromMap(map[string]string{
+		"greet.proto": `syntax = "proto3"; package greet;
+message Reply { string text = 1; }
+service Greeter { rpc Greet(Reply) returns (Reply); }`,
+	})}
+	fds, err := parser.ParseFiles("greet.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	method := fds[0].FindService("greet.Greeter").FindMethodByName("Greet")
+	// text sent as a varint
+	b := []byte{0x08, 0x2a}
+
+	body, err := parseResponseBytes(method, b, &RequestData{})
+	if err != nil || !strings.Contains(body, "response is not a valid greet.Reply") || !strings.Contains(body, `"value": 42`) {
+		t.Fatalf("want the raw view, got %s %v", body, err)
+	}
+
+	codec := &fallbackCodec{}
+	msg := dynamic.NewMessage(method.GetOutputType())
+	if err := codec.Unmarshal(b, msg); err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := codec.undecoded(); err == nil || hex.EncodeToString(raw) != "082a" {
+		t.Fatalf("want the bytes kept, got %x %v", raw, err)
+	}
+	// the end of a stream receives nothing and must not repeat the last failure
+	if _, err := codec.undecoded(); err != nil {
+		t.Fatalf("the failure should be reported once: %v", err)
+	}
+	if err := codec.Unmarshal(b, msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := codec.Unmarshal([]byte{0x0a, 0x01, 0x61}, msg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := codec.undecoded(); err != nil {
+		t.Fatalf("a valid response should clear the failure: %v", err)
+	}
+
+	// a message of another type decodes into unknown fields without an error
+	other := []byte{0x12, 0x01, 0x78}
+	if err := codec.Unmarshal(other, dynamic.NewMessage(method.GetOutputType())); err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := codec.undecoded(); err == nil || hex.EncodeToString(raw) != "120178" {
+		t.Fatalf("want the bytes of the wrong type kept, got %x %v", raw, err)
+	}
+	body, err = parseResponseBytes(method, other, &RequestData{})
+	if err != nil || !strings.Contains(body, "undeclared fields 2") || !strings.Contains(body, `"value": "x"`) {
+		t.Fatalf("want the raw view of the wrong type, got %s %v", body, err)
+	}
+}
+
+func TestJsonOptions(t *testing.T) {
+	parser := protoparse.Parser{Accessor: protoparse.FileContentsFromMap(map[string]string{
+		"stats.proto": `syntax = "proto3"; package stats;
+import "google/protobuf/wrappers.proto";
+enum Level { LEVEL_UNSPECIFIED = 0; HIGH = 1; }
+message Point { int64 at = 1; }
+message Stats { int64 total_count = 1; bool done = 2; Level level = 3; map<string, uint64> sizes = 4;
+  repeated sint64 deltas = 5; repeated Point points = 6; google.protobuf.Int64Value limit = 7; string note = 8; }`,
+	})}
+	fds, err := parser.ParseFiles("stats.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := dynamic.NewMessage(fds[0].FindMessage("stats.Stats"))
+	if err := msg.UnmarshalJSON([]byte(`{"totalCount": "12", "level": "HIGH", "sizes": {"a": "18446744073709551615"},
+		"deltas": ["-3"], "points": [{"at": "7"}], "limit": "9", "note": "<42>"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		opts *JsonOptions
+		want string
+	}{
+		{nil, "{\n  \"totalCount\": \"12\",\n  \"level\": \"HIGH\","},
+		{&JsonOptions{Compact: true, EmitDefaults: true}, `"done":false`},
+		{&JsonOptions{Compact: true, OrigName: true, EnumsAsInts: true}, `{"total_count":"12","level":1,`},
+		{&JsonOptions{Compact: true, Int64AsNumbers: true},
+			`{"totalCount":12,"level":"HIGH","sizes":{"a":18446744073709551615},"deltas":[-3],"points":[{"at":7}],"limit":9,"note":"<42>"}`},
+	}
+	for _, c := range cases {
+		body, err := renderMessage(msg, &RequestData{JsonOptions: c.opts})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(body, c.want) {
+			t.Fatalf("options %+v: want %s in %s", c.opts, c.want, body)
+		}
+	}
+}
+
+func TestReadBinaryBase64(t *testing.T) {
+	for _, data := range []string{"+/8=", "+/8", "-_8", " +/\n8= "} {
+		b, err := ReadBinary(data, FormatBase64)
+		if err != nil || hex.EncodeToString(b) != "fbff" {
+			t.Fatalf("%q: got %x %v", data, b, err)
+		}
+	}
+	if _, err := ReadBinary("+/8*", FormatBase64); err == nil {
+		t.Fatal("invalid base64 should fail")
+	}
+}
@@ -0,0 +1,280 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	grpcWebContentType     = "application/grpc-web+proto"
+	grpcWebTextContentType = "application/grpc-web-text+proto"
+	// the trailer frame has the most significant bit of the flag byte set
+	trailerFlag byte = 0x80
+)
+
+var reservedHeaders = []string{"grpc-status", "grpc-message", "grpc-status-details-bin", "content-type", "content-length", "date", "server", "vary"}
+
+func (c *Client) invokeWeb(req *RequestData) {
+	if req.MethodMode != Unary && req.MethodMode != ServerStream {
+		c.fail(req, nil, nil, errors.Errorf("%s supports only unary and server streaming methods", req.Transport))
+		return
+	}
+
+	methodDesc, err := findMethodDesc(req.ProtoPath, req.IncludeDirs, req.ServiceFullyName, req.MethodName)
+	if err != nil {
+		c.fail(req, nil, nil, err)
+		return
+	}
+	reqMsg, err := buildRequest(methodDesc, req)
+	if err != nil {
+		c.fail(req, nil, nil, err)
+		return
+	}
+	msg, err := reqMsg.Marshal()
+	if err != nil {
+		c.fail(req, nil, nil, err)
+		return
+	}
+
+	text := req.Transport == TransportGrpcWebText
+	contentType := grpcWebContentType
+	body := encodeFrame(0, msg)
+	if text {
+		contentType = grpcWebTextContentType
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tr := newHTTPTrace()
+	path := "/" + req.ServiceFullyName + "/" + req.MethodName
+	httpCli, httpReq, err := c.newHTTPRequest(ctx, req, path, bytes.NewReader(body), tr)
+	if err != nil {
+		cancel()
+		c.fail(req, nil, tr, err)
+		return
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	httpReq.Header.Set("Accept", contentType)
+	httpReq.Header.Set("X-Grpc-Web", "1")
+	httpReq.Header.Set("X-User-Agent", "grpc-web-uprpc/1.0")
+
+	// streams are stoppable while waiting for the response headers
+	st := &stream{
+		req:        req,
+		trace:      tr,
+		methodMode: req.MethodMode,
+		methodDesc: methodDesc,
+		cancel:     cancel,
+	}
+	if req.MethodMode != Unary {
+		streams.put(req.Id, st)
+	}
+	resp, err := httpCli.Do(httpReq)
+	if err != nil {
+		cancel()
+		if _, ok := streams.get(req.Id); ok || req.MethodMode == Unary {
+			c.fail(req, nil, tr, err)
+		}
+		return
+	}
+
+	if req.MethodMode == Unary {
+		defer cancel()
+		c.readWeb(req, methodDesc, resp, text, tr)
+		return
+	}
+	go c.readWeb(req, methodDesc, resp, text, tr)
+}
+
+// readWeb reads the data frames of a grpc-web response until the trailer frame.
+// Unary responses are emitted once together with the trailers.
+func (c *Client) readWeb(req *RequestData, methodDesc *desc.MethodDescriptor, resp *http.Response, text bool, tr *trace) {
+	defer func() {
+		// drain the body so the connection can be reused
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK && resp.Header.Get("grpc-status") == "" {
+		c.fail(req, nil, tr, status.Error(httpStatusCode(resp.StatusCode), resp.Status))
+		return
+	}
+
+	// initial metadata goes with the first message, the trailers with the end
+	header := parseHeaders(resp.Header, reservedHeaders...)
+	var last []byte
+	trailer, err := readWebFrames(resp.Body, text, func(payload []byte) error {
+		tr.markMessage(time.Now())
+		if req.MethodMode == Unary {
+			last = payload
+			return nil
+		}
+		body, err := parseResponseBytes(methodDesc, payload, req)
+		if err != nil {
+			return err
+		}
+		emitMsg(c.ctx, req.Id, body, header, tr.timing())
+		header = nil
+		return nil
+	})
+	if err != nil {
+		if _, ok := streams.get(req.Id); !ok && req.MethodMode != Unary {
+			// stopped by the user
+			return
+		}
+		c.fail(req, header, tr, err)
+		return
+	}
+	tr.markEnd(time.Now())
+
+	var mds []Metadata
+	if trailer == nil {
+		// trailers-only responses carry the status in the http headers
+		trailer, mds = resp.Header, header
+	} else {
+		mds = append(header, parseHeaders(trailer, reservedHeaders...)...)
+	}
+	if err := trailerStatus(trailer); err != nil {
+		c.fail(req, mds, tr, err)
+		return
+	}
+	// like the grpc transport, streams end with an empty message carrying the trailers
+	body, err := parseResponseBytes(methodDesc, last, req)
+	if err != nil {
+		c.fail(req, mds, tr, err)
+		return
+	}
+	emitMsg(c.ctx, req.Id, body, mds, tr.timing())
+	if req.MethodMode == Unary {
+		c.history.add(req, tr.timing(), nil)
+	} else {
+		c.close(req.Id)
+	}
+}
+
+// readWebFrames passes the payload of each data frame of a grpc-web body to onMessage and
+// returns the metadata of the trailer frame, nil when the body ends without one
+func readWebFrames(body io.Reader, text bool, onMessage func(payload []byte) error) (http.Header, error) {
+	var reader io.Reader = bufio.NewReader(body)
+	if text {
+		reader = &base64Reader{r: bufio.NewReader(body)}
+	}
+	for {
+		flag, payload, err := readFrame(reader)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if flag&trailerFlag != 0 {
+			return parseTrailerFrame(payload)
+		}
+		if err := onMessage(payload); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func trailerStatus(trailer http.Header) error {
+	value := trailer.Get("grpc-status")
+	if value == "" {
+		return status.Error(codes.Internal, "server closed the stream without sending trailers")
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return status.Errorf(codes.Internal, "malformed grpc-status %q", value)
+	}
+	if codes.Code(code) == codes.OK {
+		return nil
+	}
+	message, _ := url.PathUnescape(trailer.Get("grpc-message"))
+	return status.Error(codes.Code(code), message)
+}
+
+func encodeFrame(flag byte, payload []byte) []byte {
+	frame := make([]byte, 5+len(payload))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+	return frame
+}
+
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, errors.New("truncated frame header")
+		}
+		return 0, nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, errors.Wrap(err, "truncated frame")
+	}
+	return header[0], payload, nil
+}
+
+// parseTrailerFrame decodes the http/1 style header block of a grpc-web trailer frame
+func parseTrailerFrame(payload []byte) (http.Header, error) {
+	reader := textproto.NewReader(bufio.NewReader(io.MultiReader(bytes.NewReader(payload), strings.NewReader("\r\n"))))
+	header, err := reader.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("malformed trailer frame: %v", err)
+	}
+	return http.Header(header), nil
+}
+
+// base64Reader decodes grpc-web-text bodies. Servers may pad every chunk they flush,
+// so the body is decoded in groups of four characters instead of as one stream.
+type base64Reader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func (b *base64Reader) Read(p []byte) (int, error) {
+	for len(b.buf) == 0 {
+		var group [4]byte
+		for n := 0; n < 4; {
+			c, err := b.r.ReadByte()
+			if err == io.EOF && n == 0 {
+				return 0, io.EOF
+			}
+			if err == io.EOF {
+				return 0, io.ErrUnexpectedEOF
+			}
+			if err != nil {
+				return 0, err
+			}
+			if c == '\r' || c == '\n' || c == ' ' {
+				continue
+			}
+			group[n] = c
+			n++
+		}
+		decoded := make([]byte, 3)
+		n, err := base64.StdEncoding.Decode(decoded, group[:])
+		if err != nil {
+			return 0, err
+		}
+		b.buf = decoded[:n]
+	}
+	n := copy(p, b.buf)
+	b.buf = b.buf[n:]
+	return n, nil
+}
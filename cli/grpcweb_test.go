@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestReadWebFrames(t *testing.T) {
+	frames := [][]byte{
+		encodeFrame(0, []byte("first")),
+		encodeFrame(0, []byte{}),
+		encodeFrame(trailerFlag, []byte("grpc-status: 0\r\nX-Trailer: b\r\n")),
+	}
+	cases := []struct {
+		name     string
+		text     bool
+		frames   [][]byte
+		status   string
+		messages int
+		trailer  bool
+		code     codes.Code
+	}{
+		{name: "binary", frames: frames, messages: 2, trailer: true},
+		{name: "text", text: true, frames: frames, messages: 2, trailer: true},
+		{name: "trailers-only", status: "5", code: codes.NotFound},
+		{name: "missing trailer", frames: frames[:1], messages: 1, code: codes.Internal},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Initial", "a")
+				if c.status != "" {
+					w.Header().Set("grpc-status", c.status)
+				}
+				for _, frame := range c.frames {
+					if c.text {
+						// every flushed chunk is padded on its own
+						_, _ = w.Write([]byte(base64.StdEncoding.EncodeToString(frame)))
+					} else {
+						_, _ = w.Write(frame)
+					}
+					w.(http.Flusher).Flush()
+				}
+			}))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var messages []string
+			trailer, err := readWebFrames(resp.Body, c.text, func(payload []byte) error {
+				messages = append(messages, string(payload))
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(messages) != c.messages || (c.messages > 0 && messages[0] != "first") {
+				t.Fatalf("messages: %q", messages)
+			}
+			if (trailer != nil) != c.trailer {
+				t.Fatalf("trailer: %v", trailer)
+			}
+			if trailer != nil && (trailer.Get("X-Trailer") != "b" || trailer.Get("X-Initial") != "") {
+				t.Fatalf("trailer metadata mixed with headers: %v", trailer)
+			}
+			if resp.Header.Get("X-Initial") != "a" {
+				t.Fatal("initial metadata lost")
+			}
+			if trailer == nil {
+				trailer = resp.Header
+			}
+			if code := status.Code(trailerStatus(trailer)); code != c.code {
+				t.Fatalf("want %v, got %v", c.code, code)
+			}
+		})
+	}
+}
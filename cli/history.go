@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"sync"
+)
+
+// maxHistory is the number of finished calls kept in memory
+const maxHistory = 200
+
+type Call struct {
+	Id               string  `json:"id"`
+	Host             string  `json:"host"`
+	ServiceFullyName string  `json:"serviceFullyName"`
+	MethodName       string  `json:"methodName"`
+	Error            string  `json:"error,omitempty"`
+	Timing           *Timing `json:"timing,omitempty"`
+}
+
+type history struct {
+	mu    sync.Mutex
+	size  int
+	calls []Call
+}
+
+func newHistory(size int) *history {
+	return &history{size: size}
+}
+
+func (h *history) add(req *RequestData, timing *Timing, err error) {
+	call := Call{
+		Id:               req.Id,
+		Host:             req.Host,
+		ServiceFullyName: req.ServiceFullyName,
+		MethodName:       req.MethodName,
+		Timing:           timing,
+	}
+	if err != nil {
+		call.Error = err.Error()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls = append(h.calls, call)
+	if len(h.calls) > h.size {
+		h.calls = h.calls[len(h.calls)-h.size:]
+	}
+}
+
+// list returns the finished calls, latest first
+func (h *history) list() []Call {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	calls := make([]Call, 0, len(h.calls))
+	for i := len(h.calls) - 1; i >= 0; i-- {
+		calls = append(calls, h.calls[i])
+	}
+	return calls
+}
@@ -0,0 +1,220 @@
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/http2"
+)
+
+// httpEndpoint returns the base url of the http based transports and the target to
+// dial for it. Host may be a http(s) url or any target accepted by parseTarget.
+func httpEndpoint(req *RequestData) (*url.URL, *target, error) {
+	if strings.HasPrefix(req.Host, "http://") || strings.HasPrefix(req.Host, "https://") {
+		u, err := url.Parse(strings.TrimSuffix(req.Host, "/"))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid host %q", req.Host)
+		}
+		address := u.Host
+		if u.Port() == "" {
+			if u.Scheme == "https" {
+				address = net.JoinHostPort(u.Hostname(), "443")
+			} else {
+				address = net.JoinHostPort(u.Hostname(), "80")
+			}
+		}
+		t, err := parseTarget(address, req.Authority)
+		if err != nil {
+			return nil, nil, err
+		}
+		return u, t, nil
+	}
+
+	t, err := parseTarget(req.Host, req.Authority)
+	if err != nil {
+		return nil, nil, err
+	}
+	u := &url.URL{Scheme: "http", Host: t.address}
+	if t.network == "unix" {
+		u.Host = "localhost"
+	}
+	return u, t, nil
+}
+
+// httpClient returns a cached client for the endpoint of req, clients keep their
+// connections alive between calls like the grpc connection pool does
+func (c *Client) httpClient(req *RequestData) (*http.Client, *url.URL, error) {
+	base, t, err := httpEndpoint(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	proxy, err := c.proxyFor(req.Proxy, t)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := base.Scheme + "|" + t.network
+	if t.network == "unix" {
+		key += "|" + t.address
+	}
+	if proxy != nil {
+		key += "|proxy=" + proxy.key()
+	}
+	if req.Http2 {
+		key += "|h2"
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cli, ok := c.httpClients[key]; ok {
+		return cli, base, nil
+	}
+
+	dial := func(ctx context.Context, addr string) (net.Conn, error) {
+		var dialer net.Dialer
+		if t.network == "unix" {
+			return dialer.DialContext(ctx, "unix", t.address)
+		}
+		if proxy != nil {
+			return dialProxy(ctx, proxy, addr)
+		}
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+
+	var transport http.RoundTripper
+	if req.Http2 && base.Scheme == "http" {
+		// cleartext http/2 (h2c) with prior knowledge
+		transport = &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(_, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(context.Background(), addr)
+			},
+		}
+	} else {
+		transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+				return dial(ctx, addr)
+			},
+			ForceAttemptHTTP2:   req.Http2,
+			MaxIdleConnsPerHost: 8,
+			IdleConnTimeout:     defaultIdleTimeout,
+		}
+	}
+	cli := &http.Client{Transport: transport}
+	c.httpClients[key] = cli
+	return cli, base, nil
+}
+
+// newHTTPRequest builds a POST of body to path on the endpoint of req, carrying its
+// metadata as headers and reporting connection timings to tr
+func (c *Client) newHTTPRequest(ctx context.Context, req *RequestData, path string, body io.Reader, tr *trace) (*http.Client, *http.Request, error) {
+	cli, base, err := c.httpClient(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	u := *base
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+
+	httpReq, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, httpTrace(tr)), http.MethodPost, u.String(), body)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, md := range req.Mds {
+		key := strings.ToLower(md.Key)
+		if strings.HasSuffix(key, "-bin") {
+			httpReq.Header.Add(key, base64.StdEncoding.EncodeToString(md.Value))
+		} else {
+			httpReq.Header.Add(key, string(md.Value))
+		}
+	}
+	if req.Authority != "" {
+		httpReq.Host = req.Authority
+	}
+	return cli, httpReq, nil
+}
+
+// parseHeaders converts response headers or trailers to metadata, skipping the given keys
+func parseHeaders(header http.Header, skip ...string) []Metadata {
+	var mds []Metadata
+	for key, values := range header {
+		key = strings.ToLower(key)
+		if contains(skip, key) {
+			continue
+		}
+		for i, v := range values {
+			value := []byte(v)
+			if strings.HasSuffix(key, "-bin") {
+				if b, err := decodeBinHeader(v); err == nil {
+					value = b
+				}
+			}
+			mds = append(mds, Metadata{
+				Id:    key + "_" + strconv.Itoa(i),
+				Key:   key,
+				Value: value,
+			})
+		}
+	}
+	return mds
+}
+
+// decodeBinHeader accepts padded and unpadded base64 like grpc does
+func decodeBinHeader(v string) ([]byte, error) {
+	if len(v)%4 == 0 {
+		return base64.StdEncoding.DecodeString(v)
+	}
+	return base64.RawStdEncoding.DecodeString(v)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func httpTrace(t *trace) *httptrace.ClientTrace {
+	var dnsStart, connectStart, tlsStart time.Time
+	phase := func(f func(conn *connTiming)) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if t.conn != nil {
+			f(t.conn)
+		}
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			phase(func(conn *connTiming) { conn.resolve = time.Since(dnsStart) })
+		},
+		ConnectStart: func(_, _ string) { connectStart = time.Now() },
+		ConnectDone: func(_, _ string, _ error) {
+			phase(func(conn *connTiming) { conn.connect = time.Since(connectStart) })
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			phase(func(conn *connTiming) { conn.handshake = time.Since(tlsStart) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.pending = false
+			if info.Reused {
+				t.conn = nil
+			}
+		},
+		GotFirstResponseByte: func() { t.markHeaders(time.Now()) },
+	}
+}
@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+)
+
+// JsonOptions controls how responses are rendered as json, the zero value is the proto3
+// json mapping indented by two spaces
+type JsonOptions struct {
+	// EmitDefaults renders fields holding their zero value instead of leaving them out
+	EmitDefaults bool `json:"emitDefaults,omitempty"`
+	// OrigName keys fields by their proto name instead of lowerCamel
+	OrigName    bool `json:"origName,omitempty"`
+	EnumsAsInts bool `json:"enumsAsInts,omitempty"`
+	// Int64AsNumbers renders 64-bit integers as numbers instead of strings, values beyond
+	// 2^53 lose precision in javascript. Fields of messages packed in an Any are kept.
+	Int64AsNumbers bool `json:"int64AsNumbers,omitempty"`
+	Compact        bool `json:"compact,omitempty"`
+}
+
+// marshalJSON renders msg as json following opts, nil is the default
+func marshalJSON(msg *dynamic.Message, opts *JsonOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &JsonOptions{}
+	}
+	b, err := msg.MarshalJSONPB(&jsonpb.Marshaler{EmitDefaults: opts.EmitDefaults, OrigName: opts.OrigName, EnumsAsInts: opts.EnumsAsInts})
+	if err != nil {
+		return nil, err
+	}
+	if opts.Int64AsNumbers {
+		var buf bytes.Buffer
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err := copyJSON(dec, &buf, &jsonSlot{md: msg.GetMessageDescriptor()}); err != nil {
+			return nil, errors.Wrap(err, "render int64 as numbers error")
+		}
+		b = buf.Bytes()
+	}
+	if opts.Compact {
+		return b, nil
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, b, "", "  "); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// jsonSlot is the place of a json value in a message, the object of md or the value of fd,
+// its list or map unless elem. A nil slot is a value the schema says nothing about.
+type jsonSlot struct {
+	md   *desc.MessageDescriptor
+	fd   *desc.FieldDescriptor
+	elem bool
+}
+
+func fieldSlot(fd *desc.FieldDescriptor, elem bool) *jsonSlot {
+	if (elem || !fd.IsRepeated()) && fd.GetMessageType() != nil {
+		return &jsonSlot{md: fd.GetMessageType()}
+	}
+	return &jsonSlot{fd: fd, elem: elem}
+}
+
+func (s *jsonSlot) member(key string) *jsonSlot {
+	switch {
+	case s == nil:
+		return nil
+	case s.fd != nil && s.fd.IsMap() && !s.elem:
+		return fieldSlot(s.fd.GetMapValueType(), false)
+	case s.md != nil && !strings.HasPrefix(s.md.GetFullyQualifiedName(), "google.protobuf."):
+		// well-known types have json forms of their own
+		fd := s.md.FindFieldByJSONName(key)
+		if fd == nil {
+			fd = s.md.FindFieldByName(key)
+		}
+		if fd != nil {
+			return fieldSlot(fd, false)
+		}
+	}
+	return nil
+}
+
+func (s *jsonSlot) item() *jsonSlot {
+	if s != nil && s.fd != nil && s.fd.IsRepeated() && !s.fd.IsMap() && !s.elem {
+		return fieldSlot(s.fd, true)
+	}
+	return nil
+}
+
+// int64 tells whether the slot holds a 64-bit integer, which protojson quotes
+func (s *jsonSlot) int64() bool {
+	switch {
+	case s == nil:
+		return false
+	case s.md != nil:
+		name := s.md.GetFullyQualifiedName()
+		return name == "google.protobuf.Int64Value" || name == "google.protobuf.UInt64Value"
+	case s.elem || !s.fd.IsRepeated():
+		switch s.fd.GetType() {
+		case dpb.FieldDescriptorProto_TYPE_INT64, dpb.FieldDescriptorProto_TYPE_UINT64,
+			dpb.FieldDescriptorProto_TYPE_SINT64, dpb.FieldDescriptorProto_TYPE_FIXED64,
+			dpb.FieldDescriptorProto_TYPE_SFIXED64:
+			return true
+		}
+	}
+	return false
+}
+
+// copyJSON copies the next value of dec to buf compactly, unquoting the 64-bit integers of s
+func copyJSON(dec *json.Decoder, buf *bytes.Buffer, s *jsonSlot) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '{' {
+			buf.WriteByte('{')
+			for i := 0; dec.More(); i++ {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				writeJSONValue(buf, key)
+				buf.WriteByte(':')
+				if err := copyJSON(dec, buf, s.member(key.(string))); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte('}')
+		} else {
+			buf.WriteByte('[')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				if err := copyJSON(dec, buf, s.item()); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte(']')
+		}
+		// the closing delimiter
+		_, err = dec.Token()
+		return err
+	case string:
+		if s.int64() && isInteger(t) {
+			buf.WriteString(t)
+			return nil
+		}
+	}
+	writeJSONValue(buf, tok)
+	return nil
+}
+
+func isInteger(s string) bool {
+	digits := strings.TrimPrefix(s, "-")
+	return digits != "" && strings.Trim(digits, "0123456789") == ""
+}
+
+func writeJSONValue(buf *bytes.Buffer, v interface{}) {
+	enc := json.NewEncoder(buf)
+	// keep the strings as protojson wrote them
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(v)
+	// Encode ends values with a newline
+	buf.Truncate(buf.Len() - 1)
+}
@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// idle connections without any running call are closed after this period
+const defaultIdleTimeout = 5 * time.Minute
+
+type ConnState struct {
+	Key       string `json:"key"`
+	Host      string `json:"host"`
+	Authority string `json:"authority,omitempty"`
+	Proxy     string `json:"proxy,omitempty"`
+	State     string `json:"state"`
+	Refs      int    `json:"refs"`
+	LastUsed  int64  `json:"lastUsed"`
+}
+
+// dialOptions are the settings that make two connections to the same host different
+type dialOptions struct {
+	host      string
+	authority string
+	proxy     *Proxy
+}
+
+func (o dialOptions) key() string {
+	key := o.host
+	if o.authority != "" {
+		key += "|authority=" + o.authority
+	}
+	if o.proxy != nil {
+		key += "|proxy=" + o.proxy.key()
+	}
+	return key
+}
+
+func (o dialOptions) proxyKey() string {
+	if o.proxy == nil {
+		return ""
+	}
+	return o.proxy.key()
+}
+
+type pooledConn struct {
+	key      string
+	opts     dialOptions
+	conn     *grpc.ClientConn
+	timing   *connTiming
+	refs     int
+	lastUsed time.Time
+	// evicted connections are out of the pool and closed by their last release
+	evicted bool
+}
+
+type connPool struct {
+	mu          sync.Mutex
+	conns       map[string]*pooledConn
+	idleTimeout time.Duration
+}
+
+func newConnPool(idleTimeout time.Duration) *connPool {
+	return &connPool{
+		conns:       make(map[string]*pooledConn),
+		idleTimeout: idleTimeout,
+	}
+}
+
+// acquire returns a ready connection for opts, dialing a new one when none is cached
+// or the cached one has failed. fresh reports whether the connection was dialed by
+// this call. Every acquire must be paired with a release.
+func (p *connPool) acquire(opts dialOptions) (*pooledConn, bool, error) {
+	key := opts.key()
+	p.mu.Lock()
+	if pc, ok := p.conns[key]; ok {
+		state := pc.conn.GetState()
+		if state != connectivity.Shutdown && state != connectivity.TransientFailure {
+			pc.refs++
+			pc.lastUsed = time.Now()
+			p.mu.Unlock()
+			return pc, false, nil
+		}
+		// new calls get a fresh connection, running ones keep the broken one until they end
+		p.evict(pc)
+	}
+	p.mu.Unlock()
+
+	conn, timing, err := dial(opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pc, ok := p.conns[key]; ok {
+		// another call dialed the same key in the meantime
+		_ = conn.Close()
+		pc.refs++
+		pc.lastUsed = time.Now()
+		return pc, false, nil
+	}
+	pc := &pooledConn{key: key, opts: opts, conn: conn, timing: timing, refs: 1, lastUsed: time.Now()}
+	p.conns[key] = pc
+	return pc, true, nil
+}
+
+func (p *connPool) release(pc *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pc.refs > 0 {
+		pc.refs--
+	}
+	pc.lastUsed = time.Now()
+	if pc.evicted && pc.refs == 0 {
+		_ = pc.conn.Close()
+	}
+}
+
+// evict removes pc from the pool, closing it right away when no call uses it.
+// p.mu must be held.
+func (p *connPool) evict(pc *pooledConn) {
+	if p.conns[pc.key] == pc {
+		delete(p.conns, pc.key)
+	}
+	pc.evicted = true
+	if pc.refs == 0 {
+		_ = pc.conn.Close()
+	}
+}
+
+// reconnect closes the connection of a pool key and dials it again.
+// Calls still running on the old connection are aborted.
+func (p *connPool) reconnect(key string) error {
+	p.mu.Lock()
+	stale, ok := p.conns[key]
+	if ok {
+		delete(p.conns, key)
+		stale.evicted = true
+	}
+	p.mu.Unlock()
+	if !ok {
+		return errors.Errorf("no pooled connection for %s", key)
+	}
+	_ = stale.conn.Close()
+
+	pc, _, err := p.acquire(stale.opts)
+	if err != nil {
+		return err
+	}
+	p.release(pc)
+	return nil
+}
+
+func (p *connPool) evictIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, pc := range p.conns {
+		if pc.refs == 0 && time.Since(pc.lastUsed) > p.idleTimeout {
+			_ = pc.conn.Close()
+			delete(p.conns, key)
+		}
+	}
+}
+
+func (p *connPool) run(ctx context.Context) {
+	ticker := time.NewTicker(p.idleTimeout / 5)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			p.closeAll()
+			return
+		case <-ticker.C:
+			p.evictIdle()
+		}
+	}
+}
+
+func (p *connPool) closeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, pc := range p.conns {
+		_ = pc.conn.Close()
+		delete(p.conns, key)
+	}
+}
+
+func (p *connPool) states() []ConnState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	states := []ConnState{}
+	for _, pc := range p.conns {
+		states = append(states, ConnState{
+			Key:       pc.key,
+			Host:      pc.opts.host,
+			Authority: pc.opts.authority,
+			Proxy:     pc.opts.proxyKey(),
+			State:     pc.conn.GetState().String(),
+			Refs:      pc.refs,
+			LastUsed:  pc.lastUsed.UnixMilli(),
+		})
+	}
+	return states
+}
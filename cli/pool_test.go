@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+func serve(t *testing.T) (string, *grpc.Server) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	go func() { _ = server.Serve(lis) }()
+	return lis.Addr().String(), server
+}
+
+func TestPoolEvictsFailedConn(t *testing.T) {
+	host, server := serve(t)
+	pool := newConnPool(time.Minute)
+	opts := dialOptions{host: host, authority: "api.example.com"}
+	pc, fresh, err := pool.acquire(opts)
+	if err != nil || !fresh {
+		t.Fatalf("first acquire should dial: %v", err)
+	}
+
+	server.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for state := pc.conn.GetState(); state != connectivity.TransientFailure; state = pc.conn.GetState() {
+		if !pc.conn.WaitForStateChange(ctx, state) {
+			t.Fatalf("connection did not fail, state %v", state)
+		}
+	}
+
+	// the failed connection is still in use but must not be handed out again
+	if _, _, err := pool.acquire(opts); err == nil {
+		t.Fatal("acquire should dial the stopped server again and fail")
+	}
+	if len(pool.states()) != 0 || pc.conn.GetState() == connectivity.Shutdown {
+		t.Fatal("failed connection should leave the pool but stay open for its call")
+	}
+	pool.release(pc)
+	if pc.conn.GetState() != connectivity.Shutdown {
+		t.Fatal("last release of an evicted connection should close it")
+	}
+}
+
+func TestPoolReconnect(t *testing.T) {
+	host, server := serve(t)
+	defer server.Stop()
+	pool := newConnPool(time.Minute)
+	defer pool.closeAll()
+	opts := dialOptions{host: host, authority: "api.example.com"}
+	old, _, err := pool.acquire(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.release(old)
+
+	if err := pool.reconnect(host); err == nil {
+		t.Fatal("a bare host is not a pool key")
+	}
+	if err := pool.reconnect(opts.key()); err != nil {
+		t.Fatal(err)
+	}
+	states := pool.states()
+	if len(states) != 1 || states[0].Authority != "api.example.com" || old.conn.GetState() != connectivity.Shutdown {
+		t.Fatalf("want the connection redialed with its options, got %+v", states)
+	}
+}
+
+func TestDialOptionsKeyCredentials(t *testing.T) {
+	key := func(password string) string {
+		return dialOptions{host: "h:1", proxy: &Proxy{Type: ProxyHTTP, Address: "p:8080", Username: "u", Password: password}}.key()
+	}
+	if key("old") == key("new") {
+		t.Fatal("a changed proxy password should not reuse the pooled connection")
+	}
+	if strings.Contains(key("secret"), "secret") {
+		t.Fatalf("password leaks into the key %s", key("secret"))
+	}
+}
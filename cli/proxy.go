@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/proxy"
+)
+
+const (
+	ProxyHTTP   = "http"
+	ProxySOCKS5 = "socks5"
+)
+
+// Proxy routes outgoing connections through an HTTP CONNECT or SOCKS5 proxy.
+// NoProxy entries are host names, domain suffixes like .example.com, IPs, CIDRs or *.
+type Proxy struct {
+	Type     string   `json:"type,omitempty"`
+	Address  string   `json:"address,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	NoProxy  []string `json:"noProxy,omitempty"`
+}
+
+func (p *Proxy) validate() error {
+	switch p.Type {
+	case ProxyHTTP, ProxySOCKS5:
+	default:
+		return fmt.Errorf("unsupported proxy type %q, expected %s or %s", p.Type, ProxyHTTP, ProxySOCKS5)
+	}
+	if err := validateHostPort(p.Address); err != nil {
+		return fmt.Errorf("invalid proxy address %q: %s", p.Address, err.Error())
+	}
+	return nil
+}
+
+// key identifies the proxy in the connection pool. The password only enters as part of
+// a hash, so changing it dials new connections without showing it in the pool state.
+func (p *Proxy) key() string {
+	if p.Username == "" && p.Password == "" {
+		return p.Type + "://" + p.Address
+	}
+	sum := sha256.Sum256([]byte(p.Username + ":" + p.Password))
+	return p.Type + "://" + p.Username + "@" + p.Address + "#" + hex.EncodeToString(sum[:6])
+}
+
+// bypass reports whether host should be connected to directly
+func (p *Proxy) bypass(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	ip := net.ParseIP(host)
+	for _, entry := range p.NoProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case entry == "*":
+			return true
+		case strings.Contains(entry, "/"):
+			if _, cidr, err := net.ParseCIDR(entry); err == nil && ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		case strings.HasPrefix(entry, "."):
+			if strings.HasSuffix(host, entry) || host == entry[1:] {
+				return true
+			}
+		default:
+			if host == entry || strings.HasSuffix(host, "."+entry) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// proxyFor returns the proxy to use for t, the proxy of the request (environment)
+// overrides the global one. Unix sockets are never proxied.
+func (c *Client) proxyFor(reqProxy *Proxy, t *target) (*Proxy, error) {
+	p := reqProxy
+	if p == nil || p.Type == "" {
+		c.mu.Lock()
+		p = c.proxy
+		c.mu.Unlock()
+	}
+	if p == nil || p.Type == "" {
+		return nil, nil
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if t.network == "unix" || p.bypass(t.address) {
+		return nil, nil
+	}
+	return p, nil
+}
+
+func (c *Client) SetProxy(p *Proxy) error {
+	if p != nil && p.Type != "" {
+		if err := p.validate(); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.proxy = p
+	return nil
+}
+
+func (c *Client) Proxy() *Proxy {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.proxy
+}
+
+func dialProxy(ctx context.Context, p *Proxy, addr string) (net.Conn, error) {
+	switch p.Type {
+	case ProxySOCKS5:
+		var auth *proxy.Auth
+		if p.Username != "" {
+			auth = &proxy.Auth{User: p.Username, Password: p.Password}
+		}
+		dialer, err := proxy.SOCKS5("tcp", p.Address, auth, &net.Dialer{})
+		if err != nil {
+			return nil, err
+		}
+		conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, errors.Wrap(err, "socks5 proxy error")
+		}
+		return conn, nil
+	default:
+		return dialHTTPConnect(ctx, p, addr)
+	}
+}
+
+func dialHTTPConnect(ctx context.Context, p *Proxy, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", p.Address)
+	if err != nil {
+		return nil, errors.Wrap(err, "connect proxy error")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: addr},
+		Header: map[string][]string{"User-Agent": {"uprpc"}},
+	}
+	if p.Username != "" {
+		credential := base64.StdEncoding.EncodeToString([]byte(p.Username + ":" + p.Password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credential)
+	}
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "http proxy error")
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "http proxy error")
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("http proxy refused CONNECT to %s: %s", addr, resp.Status)
+	}
+	return &bufConn{Conn: conn, r: reader}, nil
+}
+
+// bufConn keeps the bytes the proxy sent after its CONNECT response
+type bufConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
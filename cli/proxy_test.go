@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyBypass(t *testing.T) {
+	p := &Proxy{NoProxy: []string{"localhost", ".internal.example.com", "10.0.0.0/8", "::1", " Plain.Example.org "}}
+	cases := []struct {
+		host   string
+		bypass bool
+	}{
+		{host: "localhost", bypass: true},
+		{host: "localhost:8080", bypass: true},
+		{host: "api.localhost", bypass: true},
+		{host: "notlocalhost", bypass: false},
+		{host: "internal.example.com:443", bypass: true},
+		{host: "a.b.internal.example.com", bypass: true},
+		{host: "external.example.com", bypass: false},
+		{host: "10.1.2.3:9000", bypass: true},
+		{host: "11.1.2.3:9000", bypass: false},
+		{host: "[::1]:50051", bypass: true},
+		{host: "PLAIN.example.org", bypass: true},
+	}
+	for _, c := range cases {
+		t.Run(c.host, func(t *testing.T) {
+			if got := p.bypass(c.host); got != c.bypass {
+				t.Fatalf("bypass(%s) = %v", c.host, got)
+			}
+		})
+	}
+	if !(&Proxy{NoProxy: []string{"*"}}).bypass("anything:1") {
+		t.Fatal("* should bypass every host")
+	}
+}
+
+func TestDialHTTPConnect(t *testing.T) {
+	credential := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret"))
+	targets := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Proxy-Authorization") != credential {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		targets <- r.Host
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// the first bytes of the tunnel come with the response
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\nhello"))
+	}))
+	defer server.Close()
+	address := strings.TrimPrefix(server.URL, "http://")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := dialProxy(ctx, &Proxy{Type: ProxyHTTP, Address: address, Username: "user", Password: "secret"}, "backend:50051")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(conn)
+	_ = conn.Close()
+	if target := <-targets; string(b) != "hello" || target != "backend:50051" {
+		t.Fatalf("want the tunnel to backend:50051, got %q to %s", b, target)
+	}
+
+	_, err = dialProxy(ctx, &Proxy{Type: ProxyHTTP, Address: address, Username: "user", Password: "wrong"}, "backend:50051")
+	if err == nil || !strings.Contains(err.Error(), "407") {
+		t.Fatalf("want the refusal of the proxy, got %v", err)
+	}
+}
+
+func TestDialSOCKS5(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	targets := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		targets <- serveSOCKS5(conn, "user", "secret")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := dialProxy(ctx, &Proxy{Type: ProxySOCKS5, Address: listener.Addr().String(), Username: "user", Password: "secret"}, "backend:50051")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(conn)
+	_ = conn.Close()
+	if target := <-targets; string(b) != "hello" || target != "backend:50051" {
+		t.Fatalf("want the tunnel to backend:50051, got %q to %s", b, target)
+	}
+}
+
+// serveSOCKS5 answers one CONNECT with username/password authentication, returning the
+// requested address
+func serveSOCKS5(conn net.Conn, username, password string) string {
+	r := bufio.NewReader(conn)
+	read := func(n int) []byte {
+		b := make([]byte, n)
+		_, _ = io.ReadFull(r, b)
+		return b
+	}
+	// greeting: version, methods
+	methods := read(2)[1]
+	read(int(methods))
+	_, _ = conn.Write([]byte{5, 2})
+	// username/password sub-negotiation
+	read(1)
+	user := string(read(int(read(1)[0])))
+	pass := string(read(int(read(1)[0])))
+	if user != username || pass != password {
+		_, _ = conn.Write([]byte{1, 1})
+		return ""
+	}
+	_, _ = conn.Write([]byte{1, 0})
+	// request: version, command, reserved, address type
+	header := read(4)
+	var host string
+	switch header[3] {
+	case 1:
+		host = net.IP(read(4)).String()
+	case 3:
+		host = string(read(int(read(1)[0])))
+	case 4:
+		host = net.IP(read(16)).String()
+	}
+	port := binary.BigEndian.Uint16(read(2))
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	_, _ = conn.Write([]byte("hello"))
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
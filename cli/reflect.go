@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"time"
+	uproto "uprpc/proto"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/grpcreflect"
+	"github.com/pkg/errors"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+// Reflect fetches the files declaring the given services from the server reflection of
+// req.Host, or of every service the server lists when services is empty
+func (c *Client) Reflect(req *RequestData, services []string) ([]*desc.FileDescriptor, error) {
+	var files []*desc.FileDescriptor
+	err := c.withReflection(req, func(rc *grpcreflect.Client) error {
+		if len(services) == 0 {
+			var err error
+			if services, err = rc.ListServices(); err != nil {
+				return errors.Wrap(err, "list services by reflection error")
+			}
+		}
+		seen := map[string]bool{}
+		for _, service := range services {
+			if strings.HasPrefix(service, "grpc.reflection.") {
+				continue
+			}
+			sd, err := rc.ResolveService(service)
+			if err != nil {
+				return errors.Wrapf(err, "resolve service %s by reflection error", service)
+			}
+			if fd := sd.GetFile(); !seen[fd.GetName()] {
+				seen[fd.GetName()] = true
+				files = append(files, fd)
+			}
+		}
+		return nil
+	})
+	return files, err
+}
+
+// Verify compares the loaded services with the ones served at req.Host, every loaded
+// service when services is empty. An empty result means the server matches the local schema.
+func (c *Client) Verify(req *RequestData, services []string) ([]uproto.Difference, error) {
+	if len(services) == 0 {
+		services = uproto.Services()
+	}
+	if len(services) == 0 {
+		return nil, errors.New("no services are loaded to verify")
+	}
+	diffs := []uproto.Difference{}
+	err := c.withReflection(req, func(rc *grpcreflect.Client) error {
+		for _, service := range services {
+			local := uproto.FindService(service)
+			if local == nil {
+				return errors.Errorf("service %s is not loaded", service)
+			}
+			remote, err := rc.ResolveService(service)
+			if err != nil && !grpcreflect.IsElementNotFoundError(err) {
+				return errors.Wrapf(err, "resolve service %s by reflection error", service)
+			}
+			diffs = append(diffs, uproto.Diff(local, remote)...)
+		}
+		return nil
+	})
+	return diffs, err
+}
+
+func (c *Client) withReflection(req *RequestData, f func(rc *grpcreflect.Client) error) error {
+	stub, err := c.createStub(req)
+	if err != nil {
+		return err
+	}
+	defer stub.close()
+
+	ctx, cancel := context.WithTimeout(buildContext(&req.Mds, nil), 10*time.Second)
+	defer cancel()
+	rc := grpcreflect.NewClient(ctx, rpb.NewServerReflectionClient(stub.pc.conn))
+	defer rc.Reset()
+	return f(rc)
+}
+
+// Schema is one side of a comparison, local sources at Path or the reflection of Host
+type Schema struct {
+	Path        string     `json:"path,omitempty"`
+	IncludeDirs []string   `json:"includeDirs,omitempty"`
+	Host        string     `json:"host,omitempty"`
+	Authority   string     `json:"authority,omitempty"`
+	Mds         []Metadata `json:"mds,omitempty"`
+}
+
+func (c *Client) LoadSchema(schema *Schema) ([]*desc.FileDescriptor, error) {
+	if schema.Host != "" {
+		return c.Reflect(&RequestData{Host: schema.Host, Authority: schema.Authority, Mds: schema.Mds}, nil)
+	}
+	return uproto.LoadSource(schema.Path, schema.IncludeDirs)
+}
+
+// Breaking lists the changes from oldSchema to newSchema that break existing clients
+func (c *Client) Breaking(oldSchema, newSchema *Schema) ([]uproto.Change, error) {
+	oldFiles, err := c.LoadSchema(oldSchema)
+	if err != nil {
+		return nil, errors.Wrap(err, "load old schema error")
+	}
+	newFiles, err := c.LoadSchema(newSchema)
+	if err != nil {
+		return nil, errors.Wrap(err, "load new schema error")
+	}
+	return uproto.Compare(oldFiles, newFiles), nil
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Status is the grpc status of a failed call, every transport maps its errors onto it
+type Status struct {
+	Code    int32          `json:"code"`
+	Name    string         `json:"name"`
+	Message string         `json:"message"`
+	Details []StatusDetail `json:"details,omitempty"`
+	// Meta holds protocol specific error fields like the twirp meta map
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+// StatusDetail is one google.protobuf.Any of the status details
+type StatusDetail struct {
+	Type  string `json:"type"`
+	Value []byte `json:"value"`
+}
+
+func toStatus(err error) *Status {
+	s, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	st := &Status{
+		Code:    int32(s.Code()),
+		Name:    s.Code().String(),
+		Message: s.Message(),
+	}
+	for _, detail := range s.Proto().GetDetails() {
+		st.Details = append(st.Details, StatusDetail{
+			Type:  strings.TrimPrefix(detail.GetTypeUrl(), "type.googleapis.com/"),
+			Value: detail.GetValue(),
+		})
+	}
+	if se, ok := err.(*statusError); ok {
+		st.Meta = se.meta
+	}
+	return st
+}
+
+// statusError is a grpc status carrying the error fields of another protocol
+type statusError struct {
+	status *status.Status
+	meta   map[string]string
+}
+
+func (e *statusError) Error() string {
+	return e.status.Err().Error()
+}
+
+func (e *statusError) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// codeNames maps the snake_case code names Connect and Twirp put into error bodies
+var codeNames = map[string]codes.Code{
+	"canceled":            codes.Canceled,
+	"unknown":             codes.Unknown,
+	"invalid_argument":    codes.InvalidArgument,
+	"deadline_exceeded":   codes.DeadlineExceeded,
+	"not_found":           codes.NotFound,
+	"already_exists":      codes.AlreadyExists,
+	"permission_denied":   codes.PermissionDenied,
+	"resource_exhausted":  codes.ResourceExhausted,
+	"failed_precondition": codes.FailedPrecondition,
+	"aborted":             codes.Aborted,
+	"out_of_range":        codes.OutOfRange,
+	"unimplemented":       codes.Unimplemented,
+	"internal":            codes.Internal,
+	"unavailable":         codes.Unavailable,
+	"data_loss":           codes.DataLoss,
+	"unauthenticated":     codes.Unauthenticated,
+	// twirp only
+	"malformed": codes.InvalidArgument,
+	"bad_route": codes.Unimplemented,
+}
+
+// codeOfName returns the code of a Connect or Twirp code name, Unknown for other names
+func codeOfName(name string) codes.Code {
+	if code, ok := codeNames[name]; ok {
+		return code
+	}
+	return codes.Unknown
+}
+
+// httpStatusCode maps the http status of a response without grpc-status to a grpc code,
+// see https://github.com/grpc/grpc/blob/master/doc/http-grpc-status-mapping.md
+func httpStatusCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
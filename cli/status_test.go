@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestHTTPErrors(t *testing.T) {
+	detail := base64.RawStdEncoding.EncodeToString([]byte("info"))
+	connect, twirp := connectHTTPError, twirpHTTPError
+	cases := []struct {
+		name    string
+		mapper  func(*http.Response, []byte) error
+		status  int
+		body    string
+		code    codes.Code
+		message string
+		details []string
+		meta    map[string]string
+	}{
+		{name: "connect error", mapper: connect, status: http.StatusNotFound, body: `{"code":"not_found","message":"no such book","details":[{"type":"google.rpc.ErrorInfo","value":"` + detail + `"}]}`,
+			code: codes.NotFound, message: "no such book", details: []string{"google.rpc.ErrorInfo"}},
+		{name: "connect unknown code", mapper: connect, status: http.StatusBadRequest, body: `{"code":"teapot","message":"short and stout"}`, code: codes.Unknown, message: "short and stout"},
+		{name: "connect plain body", mapper: connect, status: http.StatusBadGateway, body: "bad gateway", code: codes.Unavailable, message: "502 Bad Gateway"},
+		{name: "twirp error", mapper: twirp, status: http.StatusNotFound, body: `{"code":"not_found","msg":"no such book","meta":{"book_id":"42","retry":"false"}}`,
+			code: codes.NotFound, message: "no such book", meta: map[string]string{"book_id": "42", "retry": "false"}},
+		{name: "twirp only code", mapper: twirp, status: http.StatusNotFound, body: `{"code":"bad_route","msg":"no handler"}`, code: codes.Unimplemented, message: "no handler"},
+		{name: "twirp plain body", mapper: twirp, status: http.StatusServiceUnavailable, body: "down", code: codes.Unavailable, message: "503 Service Unavailable"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			recorder.WriteHeader(c.status)
+			_, _ = recorder.WriteString(c.body)
+			resp := recorder.Result()
+			b, _ := io.ReadAll(resp.Body)
+
+			st := toStatus(c.mapper(resp, b))
+			if st == nil || codes.Code(st.Code) != c.code || st.Message != c.message || len(st.Details) != len(c.details) || len(st.Meta) != len(c.meta) {
+				t.Fatalf("unexpected status %+v", st)
+			}
+			for i, typ := range c.details {
+				if st.Details[i].Type != typ {
+					t.Fatalf("detail %d: want %s, got %s", i, typ, st.Details[i].Type)
+				}
+			}
+			for key, value := range c.meta {
+				if st.Meta[key] != value {
+					t.Fatalf("meta %s: want %s, got %s", key, value, st.Meta[key])
+				}
+			}
+		})
+	}
+}
@@ -2,35 +2,155 @@ package cli
 
 import (
 	"context"
+	"net"
+	"sync"
+	"time"
+
 	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
-	"time"
 )
 
 type clientStub struct {
 	stub *grpcdynamic.Stub
-	conn *grpc.ClientConn
+	pool *connPool
+	pc   *pooledConn
+	// timing of the connection setup, nil when a pooled connection was reused
+	conn *connTiming
 }
 
-func createStub(host string) (*clientStub, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*1)
-	defer cancel()
-	// create connect
-	conn, err := grpc.DialContext(ctx, host, grpc.WithInsecure(), grpc.WithBlock())
+func (c *Client) createStub(req *RequestData) (*clientStub, error) {
+	t, err := parseTarget(req.Host, req.Authority)
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := c.proxyFor(req.Proxy, t)
+	if err != nil {
+		return nil, err
+	}
+
+	// reuse the pooled connection of the host
+	pc, fresh, err := c.pool.acquire(dialOptions{host: req.Host, authority: req.Authority, proxy: proxy})
 	if err != nil {
-		return nil, errors.Wrap(err, "connect server error")
+		return nil, err
 	}
 
 	// create stub
-	stub := grpcdynamic.NewStub(conn)
+	stub := grpcdynamic.NewStub(pc.conn)
 	client := clientStub{
 		stub: &stub,
-		conn: conn,
+		pool: c.pool,
+		pc:   pc,
+	}
+	if fresh {
+		client.conn = pc.timing
 	}
 	return &client, nil
 }
 
+// close hands the connection back to the pool, it stays open for the next call
 func (c *clientStub) close() {
-	_ = c.conn.Close()
+	c.pool.release(c.pc)
+}
+
+func (c *clientStub) newTrace() *trace {
+	return newTrace(c.conn)
+}
+
+func dial(opts dialOptions) (*grpc.ClientConn, *connTiming, error) {
+	t, err := parseTarget(opts.host, opts.authority)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*1)
+	defer cancel()
+
+	d := &timedDialer{target: t, proxy: opts.proxy}
+	dialOpts := []grpc.DialOption{
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+		grpc.WithReturnConnectionError(),
+		grpc.WithContextDialer(d.dial),
+		grpc.WithStatsHandler(statsHandler{}),
+	}
+	if t.authority != "" {
+		dialOpts = append(dialOpts, grpc.WithAuthority(t.authority))
+	}
+	// the dialer connects to the parsed target, grpc must not resolve it again
+	conn, err := grpc.DialContext(ctx, "passthrough:///"+t.address, dialOpts...)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "connect server error")
+	}
+	return conn, d.finish(), nil
+}
+
+// timedDialer resolves and connects in separate steps to time each of them
+type timedDialer struct {
+	target    *target
+	proxy     *Proxy
+	mu        sync.Mutex
+	timing    *connTiming
+	connected time.Time
+}
+
+func (d *timedDialer) dial(ctx context.Context, _ string) (net.Conn, error) {
+	start := time.Now()
+	resolved := start
+	var dialer net.Dialer
+	var conn net.Conn
+	var err error
+	if d.target.network == "unix" {
+		conn, err = dialer.DialContext(ctx, "unix", d.target.address)
+	} else if d.proxy != nil {
+		// the proxy resolves the target, connect includes the tunnel setup
+		conn, err = dialProxy(ctx, d.proxy, d.target.address)
+	} else {
+		conn, resolved, err = d.dialTCP(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// grpc dials again after a connection loss, keep the timing of the first dial
+	if d.timing == nil {
+		d.connected = time.Now()
+		d.timing = &connTiming{resolve: resolved.Sub(start), connect: d.connected.Sub(resolved)}
+	}
+	return conn, nil
+}
+
+func (d *timedDialer) dialTCP(ctx context.Context) (net.Conn, time.Time, error) {
+	host, port, err := net.SplitHostPort(d.target.address)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	resolved := time.Now()
+
+	var dialer net.Dialer
+	var conn net.Conn
+	for _, ip := range ips {
+		if conn, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), port)); err == nil {
+			return conn, resolved, nil
+		}
+	}
+	return nil, resolved, err
+}
+
+// finish is called once the connection is ready, everything after the TCP connect
+// (TLS and the HTTP/2 preface) is accounted as handshake
+func (d *timedDialer) finish() *connTiming {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.timing == nil {
+		return &connTiming{}
+	}
+	d.timing.handshake = time.Since(d.connected)
+	return d.timing
 }
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const targetUsage = "expected host:port, unix:///path/to/socket or unix-abstract:name"
+
+// target is the parsed form of RequestData.Host
+type target struct {
+	network string
+	address string
+	// authority sent as :authority header, empty means the grpc default
+	authority string
+}
+
+func parseTarget(host, authority string) (*target, error) {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		return nil, errors.New("host is empty, " + targetUsage)
+	}
+	if authority != "" {
+		if err := validateAuthority(authority); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(host, "unix-abstract:"):
+		name := strings.TrimPrefix(host, "unix-abstract:")
+		if name == "" {
+			return nil, fmt.Errorf("invalid host %q: abstract socket name is empty, %s", host, targetUsage)
+		}
+		// abstract sockets live in their own namespace marked by a leading NUL byte
+		return &target{network: "unix", address: "\x00" + name, authority: orDefault(authority, "localhost")}, nil
+	case strings.HasPrefix(host, "unix:"):
+		path := strings.TrimPrefix(host, "unix:")
+		if strings.HasPrefix(path, "//") {
+			// unix:///abs/path, the authority part must be empty
+			path = strings.TrimPrefix(path, "//")
+			if !strings.HasPrefix(path, "/") {
+				return nil, fmt.Errorf("invalid host %q: unix target must not have an authority, %s", host, targetUsage)
+			}
+		}
+		if path == "" {
+			return nil, fmt.Errorf("invalid host %q: socket path is empty, %s", host, targetUsage)
+		}
+		return &target{network: "unix", address: path, authority: orDefault(authority, "localhost")}, nil
+	case strings.HasPrefix(host, "dns:"):
+		host = strings.TrimPrefix(strings.TrimPrefix(host, "dns:"), "///")
+	case strings.Contains(host, "://"):
+		scheme := host[:strings.Index(host, "://")]
+		return nil, fmt.Errorf("invalid host %q: unsupported scheme %q, %s", host, scheme, targetUsage)
+	}
+
+	if err := validateHostPort(host); err != nil {
+		return nil, fmt.Errorf("invalid host %q: %s, %s", host, err.Error(), targetUsage)
+	}
+	return &target{network: "tcp", address: host, authority: authority}, nil
+}
+
+func validateHostPort(hostport string) error {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		if addrErr, ok := err.(*net.AddrError); ok {
+			return errors.New(addrErr.Err)
+		}
+		return err
+	}
+	if strings.ContainsAny(host, " /\\") {
+		return fmt.Errorf("malformed host name %q", host)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+func validateAuthority(authority string) error {
+	if strings.ContainsAny(authority, " /\\@\t\r\n") {
+		return fmt.Errorf("invalid authority %q, expected host or host:port", authority)
+	}
+	return nil
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
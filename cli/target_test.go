@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"testing"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		host      string
+		authority string
+		network   string
+		address   string
+		wantErr   bool
+	}{
+		{host: "127.0.0.1:9000", network: "tcp", address: "127.0.0.1:9000"},
+		{host: "dns:///localhost:9000", network: "tcp", address: "localhost:9000"},
+		{host: "[::1]:9000", network: "tcp", address: "[::1]:9000"},
+		{host: "unix:///var/run/agent.sock", network: "unix", address: "/var/run/agent.sock"},
+		{host: "unix:agent.sock", network: "unix", address: "agent.sock"},
+		{host: "unix-abstract:agent", network: "unix", address: "\x00agent"},
+		{host: "127.0.0.1", wantErr: true},
+		{host: "127.0.0.1:0", wantErr: true},
+		{host: "http://127.0.0.1:9000", wantErr: true},
+		{host: "unix://host/var/run/agent.sock", wantErr: true},
+		{host: "127.0.0.1:9000", authority: "a b", wantErr: true},
+	}
+	for _, tt := range tests {
+		target, err := parseTarget(tt.host, tt.authority)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseTarget(%q) expected error", tt.host)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseTarget(%q) error: %v", tt.host, err)
+			continue
+		}
+		if target.network != tt.network || target.address != tt.address {
+			t.Errorf("parseTarget(%q) = %s %q, want %s %q", tt.host, target.network, target.address, tt.network, tt.address)
+		}
+	}
+}
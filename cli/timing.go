@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/stats"
+)
+
+// Timing is the breakdown of a call in milliseconds. Resolve, Connect and Handshake are
+// the durations of the connection setup phases and stay zero when a pooled connection
+// was reused. Headers, FirstMessage and End are measured from the start of the call.
+type Timing struct {
+	Start        int64   `json:"start"`
+	Reused       bool    `json:"reused"`
+	Resolve      float64 `json:"resolve"`
+	Connect      float64 `json:"connect"`
+	Handshake    float64 `json:"handshake"`
+	Headers      float64 `json:"headers"`
+	FirstMessage float64 `json:"firstMessage"`
+	End          float64 `json:"end"`
+}
+
+// connTiming is recorded by the dialer when a pooled connection is created
+type connTiming struct {
+	resolve   time.Duration
+	connect   time.Duration
+	handshake time.Duration
+}
+
+type trace struct {
+	mu   sync.Mutex
+	conn *connTiming
+	// pending is set until httptrace reports the connection of an http call, there is
+	// no timing before
+	pending      bool
+	start        time.Time
+	headers      time.Time
+	firstMessage time.Time
+	end          time.Time
+}
+
+type traceKey struct{}
+
+func newTrace(conn *connTiming) *trace {
+	return &trace{conn: conn, start: time.Now()}
+}
+
+// newHTTPTrace returns the trace of a call of the http transports, see httpTrace
+func newHTTPTrace() *trace {
+	return &trace{conn: &connTiming{}, pending: true, start: time.Now()}
+}
+
+func withTrace(ctx context.Context, t *trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+func traceFrom(ctx context.Context) *trace {
+	t, _ := ctx.Value(traceKey{}).(*trace)
+	return t
+}
+
+func (t *trace) timing() *Timing {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending {
+		return nil
+	}
+	timing := &Timing{
+		Start:        t.start.UnixMilli(),
+		Reused:       t.conn == nil,
+		Headers:      t.since(t.headers),
+		FirstMessage: t.since(t.firstMessage),
+		End:          t.since(t.end),
+	}
+	if t.conn != nil {
+		timing.Resolve = ms(t.conn.resolve)
+		timing.Connect = ms(t.conn.connect)
+		timing.Handshake = ms(t.conn.handshake)
+	}
+	return timing
+}
+
+func (t *trace) since(at time.Time) float64 {
+	if at.IsZero() {
+		return 0
+	}
+	return ms(at.Sub(t.start))
+}
+
+func (t *trace) markHeaders(at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.headers.IsZero() {
+		t.headers = at
+	}
+}
+
+func (t *trace) markMessage(at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.firstMessage.IsZero() {
+		t.firstMessage = at
+	}
+}
+
+func (t *trace) markEnd(at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.end = at
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// statsHandler feeds the rpc events of a call into the trace carried by its context
+type statsHandler struct{}
+
+func (statsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (statsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	t := traceFrom(ctx)
+	if t == nil {
+		return
+	}
+	switch s := s.(type) {
+	case *stats.Begin:
+		t.mu.Lock()
+		t.start = s.BeginTime
+		t.mu.Unlock()
+	case *stats.InHeader:
+		t.markHeaders(time.Now())
+	case *stats.InPayload:
+		t.markMessage(s.RecvTime)
+	case *stats.End:
+		t.markEnd(s.EndTime)
+	}
+}
+
+func (statsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (statsHandler) HandleConn(context.Context, stats.ConnStats) {}
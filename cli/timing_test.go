@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"testing"
+)
+
+func TestHTTPTraceTiming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &http.Transport{}}
+
+	get := func(url string) (*trace, error) {
+		tr := newHTTPTrace()
+		req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), httpTrace(tr)), http.MethodGet, url, nil)
+		resp, err := client.Do(req)
+		if err == nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		return tr, err
+	}
+
+	// nothing is known of a call that never got a connection
+	tr, err := get("http://127.0.0.1:1")
+	if err == nil || tr.timing() != nil {
+		t.Fatalf("want no timing for a failed dial, got %+v %v", tr.timing(), err)
+	}
+
+	tr, err = get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timing := tr.timing(); timing == nil || timing.Reused || timing.Headers == 0 {
+		t.Fatalf("want the timing of a fresh connection, got %+v", timing)
+	}
+	tr, err = get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timing := tr.timing(); timing == nil || !timing.Reused {
+		t.Fatalf("want the connection reused, got %+v", timing)
+	}
+}
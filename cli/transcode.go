@@ -0,0 +1,463 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	uproto "uprpc/proto"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// pathVariable matches {field.path} and {field.path=pattern} of a http rule path
+var pathVariable = regexp.MustCompile(`\{([^}=]+)(?:=([^}]*))?\}`)
+
+// gatewayError is the error body of grpc-gateway, older versions send error instead of message
+type gatewayError struct {
+	Code    int32  `json:"code"`
+	Message string `json:"message"`
+	Error   string `json:"error"`
+}
+
+func (e *gatewayError) err(httpStatus int) error {
+	code := codes.Code(e.Code)
+	if code == codes.OK {
+		code = httpStatusCode(httpStatus)
+	}
+	if e.Message == "" {
+		e.Message = e.Error
+	}
+	return status.Error(code, e.Message)
+}
+
+// invokeHTTP calls the REST mapping of a method like grpc-gateway exposes it: path variables
+// and the body come from the request message, the remaining fields become query parameters
+func (c *Client) invokeHTTP(req *RequestData) {
+	if req.MethodMode != Unary && req.MethodMode != ServerStream {
+		c.fail(req, nil, nil, errors.New("http transcoding supports only unary and server streaming methods"))
+		return
+	}
+
+	methodDesc, err := findMethodDesc(req.ProtoPath, req.IncludeDirs, req.ServiceFullyName, req.MethodName)
+	if err != nil {
+		c.fail(req, nil, nil, err)
+		return
+	}
+	rule := uproto.HttpRuleOf(methodDesc)
+	if rule == nil {
+		c.fail(req, nil, nil, errors.Errorf("method %s has no google.api.http option", methodDesc.GetFullyQualifiedName()))
+		return
+	}
+
+	msg, err := buildRequest(methodDesc, req)
+	if err != nil {
+		c.fail(req, nil, nil, err)
+		return
+	}
+	rule, path, err := bindRule(rule, msg)
+	if err != nil {
+		c.fail(req, nil, nil, err)
+		return
+	}
+	body, query, err := splitBody(rule.Body, msg)
+	if err != nil {
+		c.fail(req, nil, nil, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tr := newHTTPTrace()
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpCli, httpReq, err := c.newHTTPRequest(ctx, req, "", reader, tr)
+	if err != nil {
+		cancel()
+		c.fail(req, nil, tr, err)
+		return
+	}
+	httpReq.Method = rule.Method
+	rawPath := strings.TrimSuffix(httpReq.URL.EscapedPath(), "/") + path
+	httpReq.URL.Path, _ = url.PathUnescape(rawPath)
+	httpReq.URL.RawPath = rawPath
+	httpReq.URL.RawQuery = query.Encode()
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set("Accept", "application/json")
+
+	// streams are stoppable while waiting for the response headers
+	st := &stream{
+		req:        req,
+		trace:      tr,
+		methodMode: req.MethodMode,
+		methodDesc: methodDesc,
+		cancel:     cancel,
+	}
+	if req.MethodMode != Unary {
+		streams.put(req.Id, st)
+	}
+	resp, err := httpCli.Do(httpReq)
+	if err != nil {
+		cancel()
+		if _, ok := streams.get(req.Id); ok || req.MethodMode == Unary {
+			c.fail(req, nil, tr, err)
+		}
+		return
+	}
+
+	if req.MethodMode == Unary {
+		defer cancel()
+		c.readHTTPUnary(req, methodDesc, rule, resp, tr)
+		return
+	}
+	go c.readHTTPStream(req, methodDesc, rule, resp, tr)
+}
+
+func (c *Client) readHTTPUnary(req *RequestData, methodDesc *desc.MethodDescriptor, rule *uproto.HttpRule, resp *http.Response, tr *trace) {
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.fail(req, nil, tr, err)
+		return
+	}
+	tr.markMessage(time.Now())
+	tr.markEnd(time.Now())
+
+	mds := gatewayMetadata(resp.Header)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		c.fail(req, mds, tr, httpError(resp, b))
+		return
+	}
+	body, err := parseResponseCodec(methodDesc, CodecJSON, wrapResponseBody(rule, b), req)
+	if err != nil {
+		c.fail(req, mds, tr, err)
+		return
+	}
+	c.history.add(req, tr.timing(), nil)
+	emitMsg(c.ctx, req.Id, body, mds, tr.timing())
+}
+
+// readHTTPStream reads the newline delimited {"result": ...} or {"error": ...} objects
+// grpc-gateway writes for server streaming methods
+func (c *Client) readHTTPStream(req *RequestData, methodDesc *desc.MethodDescriptor, rule *uproto.HttpRule, resp *http.Response, tr *trace) {
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+	mds := gatewayMetadata(resp.Header)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(resp.Body)
+		c.fail(req, mds, tr, httpError(resp, b))
+		return
+	}
+
+	err := readGatewayChunks(resp.Body, func(result json.RawMessage) error {
+		tr.markMessage(time.Now())
+		body, err := parseResponseCodec(methodDesc, CodecJSON, wrapResponseBody(rule, result), req)
+		if err != nil {
+			return err
+		}
+		emitMsg(c.ctx, req.Id, body, nil, tr.timing())
+		return nil
+	})
+	tr.markEnd(time.Now())
+	if err != nil {
+		if _, ok := streams.get(req.Id); ok {
+			c.fail(req, mds, tr, err)
+		}
+		return
+	}
+
+	body, _ := parseResponseBytes(methodDesc, nil, req)
+	emitMsg(c.ctx, req.Id, body, mds, tr.timing())
+	c.close(req.Id)
+}
+
+// readGatewayChunks hands the result of every chunk of body to onResult until the body
+// ends or a chunk carries an error
+func readGatewayChunks(body io.Reader, onResult func(result json.RawMessage) error) error {
+	decoder := json.NewDecoder(body)
+	for {
+		var chunk struct {
+			Result json.RawMessage `json:"result"`
+			Error  *gatewayError   `json:"error"`
+		}
+		err := decoder.Decode(&chunk)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "decode stream error")
+		}
+		if chunk.Error != nil {
+			return chunk.Error.err(http.StatusOK)
+		}
+		if err := onResult(chunk.Result); err != nil {
+			return err
+		}
+	}
+}
+
+func httpError(resp *http.Response, b []byte) error {
+	var gwErr gatewayError
+	if json.Unmarshal(b, &gwErr) == nil && (gwErr.Code != 0 || gwErr.Message != "" || gwErr.Error != "") {
+		return gwErr.err(resp.StatusCode)
+	}
+	return status.Error(httpStatusCode(resp.StatusCode), resp.Status)
+}
+
+// gatewayMetadata returns the grpc metadata forwarded by the gateway as Grpc-Metadata- headers
+func gatewayMetadata(header http.Header) []Metadata {
+	md := http.Header{}
+	for key, values := range header {
+		lower := strings.ToLower(key)
+		if strings.HasPrefix(lower, "grpc-metadata-") {
+			md[lower[len("grpc-metadata-"):]] = values
+		}
+	}
+	return parseHeaders(md)
+}
+
+func wrapResponseBody(rule *uproto.HttpRule, b []byte) []byte {
+	if rule.ResponseBody == "" || len(b) == 0 {
+		return b
+	}
+	key, _ := json.Marshal(rule.ResponseBody)
+	return []byte(fmt.Sprintf("{%s:%s}", key, b))
+}
+
+// bindRule picks the first binding whose path variables are all set in msg, returning the
+// expanded path. The fields used by the path are cleared from msg.
+func bindRule(rule *uproto.HttpRule, msg *dynamic.Message) (*uproto.HttpRule, string, error) {
+	var firstErr error
+	for _, binding := range append([]*uproto.HttpRule{rule}, rule.AdditionalBindings...) {
+		path, fields, err := expandPath(binding.Path, msg)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for _, field := range fields {
+			clearField(msg, field)
+		}
+		return binding, path, nil
+	}
+	return nil, "", firstErr
+}
+
+func expandPath(template string, msg *dynamic.Message) (string, []string, error) {
+	// the verb suffix like :cancel is kept as is
+	var fields []string
+	var expandErr error
+	path := pathVariable.ReplaceAllStringFunc(template, func(variable string) string {
+		match := pathVariable.FindStringSubmatch(variable)
+		field, pattern := strings.TrimSpace(match[1]), match[2]
+		value, err := fieldString(msg, field)
+		if err == nil && value == "" {
+			err = errors.Errorf("path parameter %s of %s is empty", field, template)
+		}
+		if err != nil {
+			if expandErr == nil {
+				expandErr = err
+			}
+			return ""
+		}
+		fields = append(fields, field)
+		if strings.Contains(pattern, "/") || strings.Contains(pattern, "**") {
+			// multi segment variables keep their slashes
+			segments := strings.Split(value, "/")
+			for i := range segments {
+				segments[i] = url.PathEscape(segments[i])
+			}
+			return strings.Join(segments, "/")
+		}
+		return url.PathEscape(value)
+	})
+	if expandErr != nil {
+		return "", nil, expandErr
+	}
+	return path, fields, nil
+}
+
+// fieldString formats the scalar field at the dotted path of msg as a path parameter
+func fieldString(msg *dynamic.Message, fieldPath string) (string, error) {
+	names := strings.Split(fieldPath, ".")
+	current := msg
+	for i, name := range names {
+		fd := current.FindFieldDescriptorByName(name)
+		if fd == nil {
+			return "", errors.Errorf("field %s of path parameter %s not found", name, fieldPath)
+		}
+		value := current.GetField(fd)
+		if i < len(names)-1 {
+			if fd.GetMessageType() == nil || fd.IsRepeated() {
+				return "", errors.Errorf("path parameter %s is not a message field path", fieldPath)
+			}
+			next, ok := value.(*dynamic.Message)
+			if !ok {
+				return "", nil
+			}
+			current = next
+			continue
+		}
+		if fd.IsRepeated() || fd.GetMessageType() != nil {
+			return "", errors.Errorf("path parameter %s must be a scalar field", fieldPath)
+		}
+		switch v := value.(type) {
+		case []byte:
+			return base64.URLEncoding.EncodeToString(v), nil
+		case int32:
+			if fd.GetType() == descriptorpb.FieldDescriptorProto_TYPE_ENUM {
+				if ev := fd.GetEnumType().FindValueByNumber(v); ev != nil {
+					return ev.GetName(), nil
+				}
+			}
+		}
+		return fmt.Sprint(value), nil
+	}
+	return "", nil
+}
+
+func clearField(msg *dynamic.Message, fieldPath string) {
+	names := strings.Split(fieldPath, ".")
+	for _, name := range names[:len(names)-1] {
+		next, ok := msg.GetFieldByName(name).(*dynamic.Message)
+		if !ok {
+			return
+		}
+		msg = next
+	}
+	msg.ClearFieldByName(names[len(names)-1])
+}
+
+// splitBody returns the http body for the body mapping of a rule and the remaining
+// fields of msg as query parameters
+func splitBody(bodyField string, msg *dynamic.Message) ([]byte, url.Values, error) {
+	marshaler := &jsonpb.Marshaler{OrigName: true}
+	switch bodyField {
+	case "*":
+		body, err := msg.MarshalJSONPB(marshaler)
+		return body, url.Values{}, err
+	case "":
+		query, err := queryValues(msg)
+		return nil, query, err
+	}
+
+	fd := msg.FindFieldDescriptorByName(bodyField)
+	if fd == nil {
+		return nil, nil, errors.Errorf("body field %s not found in %s", bodyField, msg.GetMessageDescriptor().GetFullyQualifiedName())
+	}
+	body, err := fieldJSON(msg, fd)
+	if err != nil {
+		return nil, nil, err
+	}
+	msg.ClearField(fd)
+	query, err := queryValues(msg)
+	return body, query, err
+}
+
+// fieldJSON marshals the value of a single field, messages are sent as {} when unset
+func fieldJSON(msg *dynamic.Message, fd *desc.FieldDescriptor) ([]byte, error) {
+	if nested, ok := msg.GetField(fd).(*dynamic.Message); ok && !fd.IsRepeated() {
+		return nested.MarshalJSONPB(&jsonpb.Marshaler{OrigName: true})
+	}
+	if fd.GetMessageType() != nil && !fd.IsRepeated() {
+		return []byte("{}"), nil
+	}
+	only := dynamic.NewMessage(msg.GetMessageDescriptor())
+	only.SetField(fd, msg.GetField(fd))
+	b, err := only.MarshalJSONPB(&jsonpb.Marshaler{OrigName: true, EmitDefaults: true})
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	return fields[fd.GetName()], nil
+}
+
+// queryValues flattens the fields of msg into query parameters, nested fields are joined
+// with dots, map entries are written as field[key] and repeated fields repeat the parameter
+func queryValues(msg *dynamic.Message) (url.Values, error) {
+	b, err := msg.MarshalJSONPB(&jsonpb.Marshaler{OrigName: true})
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	flattenQuery(query, "", fields, msg.GetMessageDescriptor())
+	return query, nil
+}
+
+// flattenQuery adds the json value of a field of type md, md is nil for values that are
+// no message like the entries of a google.protobuf.Struct
+func flattenQuery(query url.Values, prefix string, value interface{}, md *desc.MessageDescriptor) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			name := key
+			if prefix != "" {
+				name = prefix + "." + key
+			}
+			var fd *desc.FieldDescriptor
+			if md != nil {
+				fd = md.FindFieldByName(key)
+			}
+			switch {
+			case fd == nil:
+				flattenQuery(query, name, v[key], nil)
+			case fd.IsMap():
+				flattenMap(query, name, v[key], fd.GetMapValueType().GetMessageType())
+			default:
+				flattenQuery(query, name, v[key], fd.GetMessageType())
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			flattenQuery(query, prefix, item, md)
+		}
+	case nil:
+	default:
+		query.Add(prefix, fmt.Sprint(v))
+	}
+}
+
+// flattenMap adds the entries of a map field as prefix[key], the form grpc-gateway parses
+func flattenMap(query url.Values, prefix string, value interface{}, valueType *desc.MessageDescriptor) {
+	entries, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key, entry := range entries {
+		flattenQuery(query, prefix+"["+key+"]", entry, valueType)
+	}
+}
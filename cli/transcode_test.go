@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	uproto "uprpc/proto"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const orderProto = `syntax = "proto3"; package shop;
+enum Kind { KIND_UNSPECIFIED = 0; GIFT = 1; }
+message Item { string sku = 1; int32 count = 2; }
+message Order {
+  string name = 1;
+  int64 shop_id = 2;
+  Item item = 3;
+  map<string, string> labels = 4;
+  repeated string tags = 5;
+  Kind kind = 6;
+  map<string, Item> items = 7;
+}`
+
+func orderMessage(t *testing.T, body string) *dynamic.Message {
+	parser := protoparse.Parser{Accessor: protoparse.FileContentsFromMap(map[string]string{"order.proto": orderProto})}
+	fds, err := parser.ParseFiles("order.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := dynamic.NewMessage(fds[0].FindMessage("shop.Order"))
+	if err := msg.UnmarshalJSON([]byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestBindRule(t *testing.T) {
+	rule := &uproto.HttpRule{Method: "GET", Path: "/v1/{name=shops/*/orders/*}", AdditionalBindings: []*uproto.HttpRule{
+		{Method: "GET", Path: "/v1/shops/{shop_id}/orders:list"},
+	}}
+	cases := []struct {
+		name    string
+		rule    *uproto.HttpRule
+		body    string
+		path    string
+		cleared string
+		err     bool
+	}{
+		{name: "first binding", rule: rule, body: `{"name":"shops/1/orders/a b","shopId":"7"}`, path: "/v1/shops/1/orders/a%20b", cleared: "name"},
+		{name: "additional binding", rule: rule, body: `{"shopId":"7"}`, path: "/v1/shops/7/orders:list", cleared: "shop_id"},
+		{name: "message variable", rule: &uproto.HttpRule{Method: "GET", Path: "/v1/{item}"}, body: `{"item":{"sku":"x"}}`, err: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			msg := orderMessage(t, c.body)
+			_, path, err := bindRule(c.rule, msg)
+			if (err != nil) != c.err {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if path != c.path {
+				t.Fatalf("want %s, got %s", c.path, path)
+			}
+			if c.cleared != "" && msg.HasFieldName(c.cleared) {
+				t.Fatalf("path parameter %s should be cleared from the message", c.cleared)
+			}
+		})
+	}
+}
+
+func TestSplitBody(t *testing.T) {
+	order := `{"shopId":"7","item":{"sku":"x","count":2},"labels":{"env":"prod"},"tags":["a","b"],"kind":"GIFT","items":{"k":{"sku":"y"}}}`
+	cases := []struct {
+		field string
+		body  string
+		query url.Values
+	}{
+		{field: "*", body: `"item":{"sku":"x","count":2}`, query: url.Values{}},
+		{field: "item", body: `{"sku":"x","count":2}`, query: url.Values{
+			"shop_id": {"7"}, "labels[env]": {"prod"}, "tags": {"a", "b"}, "kind": {"GIFT"}, "items[k].sku": {"y"},
+		}},
+		{field: "", query: url.Values{
+			"shop_id": {"7"}, "item.sku": {"x"}, "item.count": {"2"}, "labels[env]": {"prod"}, "tags": {"a", "b"}, "kind": {"GIFT"}, "items[k].sku": {"y"},
+		}},
+	}
+	for _, c := range cases {
+		t.Run("body "+c.field, func(t *testing.T) {
+			body, query, err := splitBody(c.field, orderMessage(t, order))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(body), c.body) {
+				t.Fatalf("body %s misses %s", body, c.body)
+			}
+			if query.Encode() != c.query.Encode() {
+				t.Fatalf("want query %s, got %s", c.query.Encode(), query.Encode())
+			}
+		})
+	}
+}
+
+func TestReadGatewayChunks(t *testing.T) {
+	cases := []struct {
+		name    string
+		body    string
+		results []string
+		code    codes.Code
+	}{
+		{name: "results", body: "{\"result\":{\"sku\":\"a\"}}\n{\"result\":{\"sku\":\"b\"}}\n", results: []string{`{"sku":"a"}`, `{"sku":"b"}`}},
+		{name: "error chunk", body: "{\"result\":{\"sku\":\"a\"}}\n{\"error\":{\"code\":5,\"message\":\"gone\"}}\n", results: []string{`{"sku":"a"}`}, code: codes.NotFound},
+		{name: "malformed", body: "{\"result\":", code: codes.Unknown},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var results []string
+			err := readGatewayChunks(strings.NewReader(c.body), func(result json.RawMessage) error {
+				results = append(results, string(result))
+				return nil
+			})
+			if strings.Join(results, ",") != strings.Join(c.results, ",") {
+				t.Fatalf("results: %q", results)
+			}
+			if code := status.Code(err); code != c.code {
+				t.Fatalf("want %v, got %v", c.code, err)
+			}
+		})
+	}
+}
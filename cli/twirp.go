@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/status"
+)
+
+type twirpError struct {
+	Code string            `json:"code"`
+	Msg  string            `json:"msg"`
+	Meta map[string]string `json:"meta"`
+}
+
+func (e *twirpError) err() error {
+	return &statusError{status: status.New(codeOfName(e.Code), e.Msg), meta: e.Meta}
+}
+
+// twirpHTTPError returns the error of a response with a status other than 200
+func twirpHTTPError(resp *http.Response, body []byte) error {
+	var twirpErr twirpError
+	if json.Unmarshal(body, &twirpErr) == nil && twirpErr.Code != "" {
+		return twirpErr.err()
+	}
+	return status.Error(httpStatusCode(resp.StatusCode), resp.Status)
+}
+
+// invokeTwirp posts the request to /twirp/<package.Service>/<Method>, twirp has no streaming
+func (c *Client) invokeTwirp(req *RequestData) {
+	if req.MethodMode != Unary {
+		c.fail(req, nil, nil, errors.New("twirp supports only unary methods, streaming methods cannot be called"))
+		return
+	}
+
+	methodDesc, err := findMethodDesc(req.ProtoPath, req.IncludeDirs, req.ServiceFullyName, req.MethodName)
+	if err != nil {
+		c.fail(req, nil, nil, err)
+		return
+	}
+	codec := codecOf(req)
+	msg, err := buildRequest(methodDesc, req)
+	if err != nil {
+		c.fail(req, nil, nil, err)
+		return
+	}
+	body, err := marshalCodec(msg, codec)
+	if err != nil {
+		c.fail(req, nil, nil, err)
+		return
+	}
+
+	tr := newHTTPTrace()
+	path := "/twirp/" + req.ServiceFullyName + "/" + req.MethodName
+	httpCli, httpReq, err := c.newHTTPRequest(context.Background(), req, path, bytes.NewReader(body), tr)
+	if err != nil {
+		c.fail(req, nil, tr, err)
+		return
+	}
+	contentType := "application/json"
+	if codec == CodecProto {
+		contentType = "application/protobuf"
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	httpReq.Header.Set("Accept", contentType)
+
+	resp, err := httpCli.Do(httpReq)
+	if err != nil {
+		c.fail(req, nil, tr, err)
+		return
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.fail(req, nil, tr, err)
+		return
+	}
+	tr.markMessage(time.Now())
+	tr.markEnd(time.Now())
+
+	// twirp errors are always json, whatever the request content type was
+	if resp.StatusCode != http.StatusOK {
+		c.fail(req, nil, tr, twirpHTTPError(resp, b))
+		return
+	}
+
+	respBody, err := parseResponseCodec(methodDesc, codec, b, req)
+	if err != nil {
+		c.fail(req, nil, tr, err)
+		return
+	}
+	c.history.add(req, tr.timing(), nil)
+	emitMsg(c.ctx, req.Id, respBody, nil, tr.timing())
+}
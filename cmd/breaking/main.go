@@ -0,0 +1,85 @@
+// Command breaking lists the breaking changes between two versions of a schema and exits
+// with status 1 when there are any.
+//
+//	breaking -old api-v1.protoset -new ./proto -I ./third_party
+//	breaking -old ./proto -new grpc://127.0.0.1:9000
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"uprpc/cli"
+
+	"github.com/sirupsen/logrus"
+)
+
+type includeDirs []string
+
+func (i *includeDirs) String() string {
+	return strings.Join(*i, ",")
+}
+
+func (i *includeDirs) Set(dir string) error {
+	*i = append(*i, dir)
+	return nil
+}
+
+func main() {
+	var dirs includeDirs
+	oldSource := flag.String("old", "", "old version: .proto file, descriptor set, buf image, directory or grpc://host:port for server reflection")
+	newSource := flag.String("new", "", "new version, in the same forms as -old")
+	wireOnly := flag.Bool("wire", false, "report only changes breaking the binary encoding")
+	asJSON := flag.Bool("json", false, "print the changes as json")
+	flag.Var(&dirs, "I", "include dir, may be repeated")
+	flag.Parse()
+	if *oldSource == "" || *newSource == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// errors are reported below, the progress of the parser is noise here
+	logrus.SetOutput(io.Discard)
+	c := cli.New(context.Background())
+	changes, err := c.Breaking(schema(*oldSource, dirs), schema(*newSource, dirs))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	var breaking = changes[:0]
+	for _, change := range changes {
+		if change.Wire || !*wireOnly {
+			breaking = append(breaking, change)
+		}
+	}
+
+	if *asJSON {
+		b, _ := json.MarshalIndent(breaking, "", "  ")
+		fmt.Println(string(b))
+	} else {
+		for _, change := range breaking {
+			var kinds []string
+			if change.Wire {
+				kinds = append(kinds, "wire")
+			}
+			if change.Json {
+				kinds = append(kinds, "json")
+			}
+			fmt.Printf("%s: %s [%s]\n", change.Element, change.Message, strings.Join(kinds, ","))
+		}
+	}
+	if len(breaking) > 0 {
+		os.Exit(1)
+	}
+}
+
+func schema(source string, dirs []string) *cli.Schema {
+	if strings.HasPrefix(source, "grpc://") {
+		return &cli.Schema{Host: strings.TrimPrefix(source, "grpc://")}
+	}
+	return &cli.Schema{Path: source, IncludeDirs: dirs}
+}
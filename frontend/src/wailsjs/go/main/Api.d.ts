@@ -1,16 +1,58 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
-import {main} from '../models';
 import {cli} from '../models';
+import {main} from '../models';
+
+export function Breaking(arg1:cli.Schema,arg2:cli.Schema):Promise<main.R>;
+
+export function DecodeWire(arg1:string,arg2:string,arg3:string):Promise<main.R>;
+
+export function DescribeSymbol(arg1:string):Promise<main.R>;
+
+export function FindUsages(arg1:string):Promise<main.R>;
+
+export function GetInputSchema(arg1:string):Promise<main.R>;
+
+export function GetProxy():Promise<main.R>;
+
+export function History():Promise<main.R>;
+
+export function ImportDir(arg1:string):Promise<main.R>;
+
+export function ListConns():Promise<main.R>;
+
+export function ListSymbols(arg1:string):Promise<main.R>;
+
+export function OpenBinary():Promise<main.R>;
 
 export function OpenIncludeDir():Promise<main.R>;
 
 export function OpenProto():Promise<main.R>;
 
+export function ParseBuf(arg1:string):Promise<main.R>;
+
 export function ParseProto(arg1:Array<string>,arg2:Array<string>):Promise<main.R>;
 
 export function Push(arg1:cli.RequestData):Promise<main.R>;
 
+export function ReconcileBody(arg1:string,arg2:string,arg3:string):Promise<main.R>;
+
+export function Reconnect(arg1:string):Promise<main.R>;
+
+export function RequestTemplate(arg1:string,arg2:string,arg3:string):Promise<main.R>;
+
+export function SaveBinary(arg1:string):Promise<main.R>;
+
+export function SearchSymbols(arg1:string):Promise<main.R>;
+
 export function Send(arg1:cli.RequestData):Promise<main.R>;
 
+export function SetProxy(arg1:cli.Proxy):Promise<main.R>;
+
 export function Stop(arg1:string):Promise<main.R>;
+
+export function SymbolSource(arg1:string):Promise<main.R>;
+
+export function Unwatch(arg1:string):Promise<main.R>;
+
+export function VerifyServer(arg1:cli.RequestData,arg2:Array<string>):Promise<main.R>;
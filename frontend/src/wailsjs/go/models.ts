@@ -1,24 +1,3 @@
-export namespace main {
-	
-	export class R {
-	    success?: boolean;
-	    message?: string;
-	    data?: any;
-	
-	    static createFrom(source: any = {}) {
-	        return new R(source);
-	    }
-	
-	    constructor(source: any = {}) {
-	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.success = source["success"];
-	        this.message = source["message"];
-	        this.data = source["data"];
-	    }
-	}
-
-}
-
 export namespace cli {
 	
 	export class Metadata {
@@ -39,6 +18,84 @@ export namespace cli {
 	        this.parseType = source["parseType"];
 	    }
 	}
+	export class Schema {
+	    path?: string;
+	    includeDirs?: string[];
+	    host?: string;
+	    authority?: string;
+	    mds?: Metadata[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Schema(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.includeDirs = source["includeDirs"];
+	        this.host = source["host"];
+	        this.authority = source["authority"];
+	        this.mds = this.convertValues(source["mds"], Metadata);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class JsonOptions {
+	    emitDefaults?: boolean;
+	    origName?: boolean;
+	    enumsAsInts?: boolean;
+	    int64AsNumbers?: boolean;
+	    compact?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new JsonOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.emitDefaults = source["emitDefaults"];
+	        this.origName = source["origName"];
+	        this.enumsAsInts = source["enumsAsInts"];
+	        this.int64AsNumbers = source["int64AsNumbers"];
+	        this.compact = source["compact"];
+	    }
+	}
+	export class Proxy {
+	    type?: string;
+	    address?: string;
+	    username?: string;
+	    password?: string;
+	    noProxy?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Proxy(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.address = source["address"];
+	        this.username = source["username"];
+	        this.password = source["password"];
+	        this.noProxy = source["noProxy"];
+	    }
+	}
 	export class RequestData {
 	    id?: string;
 	    protoPath?: string;
@@ -47,7 +104,15 @@ export namespace cli {
 	    methodName?: string;
 	    methodMode?: number;
 	    host?: string;
+	    authority?: string;
+	    proxy?: Proxy;
+	    transport?: string;
+	    codec?: string;
+	    http2?: boolean;
 	    body?: string;
+	    format?: string;
+	    responseFormat?: string;
+	    jsonOptions?: JsonOptions;
 	    mds?: Metadata[];
 	    includeDirs?: string[];
 	
@@ -64,7 +129,15 @@ export namespace cli {
 	        this.methodName = source["methodName"];
 	        this.methodMode = source["methodMode"];
 	        this.host = source["host"];
+	        this.authority = source["authority"];
+	        this.proxy = this.convertValues(source["proxy"], Proxy);
+	        this.transport = source["transport"];
+	        this.codec = source["codec"];
+	        this.http2 = source["http2"];
 	        this.body = source["body"];
+	        this.format = source["format"];
+	        this.responseFormat = source["responseFormat"];
+	        this.jsonOptions = this.convertValues(source["jsonOptions"], JsonOptions);
 	        this.mds = this.convertValues(source["mds"], Metadata);
 	        this.includeDirs = source["includeDirs"];
 	    }
@@ -87,6 +160,28 @@ export namespace cli {
 		    return a;
 		}
 	}
+	
+
+}
+
+export namespace main {
+	
+	export class R {
+	    success?: boolean;
+	    message?: string;
+	    data?: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new R(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.success = source["success"];
+	        this.message = source["message"];
+	        this.data = source["data"];
+	    }
+	}
 
 }
 
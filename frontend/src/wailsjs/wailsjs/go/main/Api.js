@@ -2,6 +2,50 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function Breaking(arg1, arg2) {
+  return window['go']['main']['Api']['Breaking'](arg1, arg2);
+}
+
+export function DecodeWire(arg1, arg2, arg3) {
+  return window['go']['main']['Api']['DecodeWire'](arg1, arg2, arg3);
+}
+
+export function DescribeSymbol(arg1) {
+  return window['go']['main']['Api']['DescribeSymbol'](arg1);
+}
+
+export function FindUsages(arg1) {
+  return window['go']['main']['Api']['FindUsages'](arg1);
+}
+
+export function GetInputSchema(arg1) {
+  return window['go']['main']['Api']['GetInputSchema'](arg1);
+}
+
+export function GetProxy() {
+  return window['go']['main']['Api']['GetProxy']();
+}
+
+export function History() {
+  return window['go']['main']['Api']['History']();
+}
+
+export function ImportDir(arg1) {
+  return window['go']['main']['Api']['ImportDir'](arg1);
+}
+
+export function ListConns() {
+  return window['go']['main']['Api']['ListConns']();
+}
+
+export function ListSymbols(arg1) {
+  return window['go']['main']['Api']['ListSymbols'](arg1);
+}
+
+export function OpenBinary() {
+  return window['go']['main']['Api']['OpenBinary']();
+}
+
 export function OpenIncludeDir() {
   return window['go']['main']['Api']['OpenIncludeDir']();
 }
@@ -10,6 +54,10 @@ export function OpenProto() {
   return window['go']['main']['Api']['OpenProto']();
 }
 
+export function ParseBuf(arg1) {
+  return window['go']['main']['Api']['ParseBuf'](arg1);
+}
+
 export function ParseProto(arg1, arg2) {
   return window['go']['main']['Api']['ParseProto'](arg1, arg2);
 }
@@ -18,10 +66,46 @@ export function Push(arg1) {
   return window['go']['main']['Api']['Push'](arg1);
 }
 
+export function ReconcileBody(arg1, arg2, arg3) {
+  return window['go']['main']['Api']['ReconcileBody'](arg1, arg2, arg3);
+}
+
+export function Reconnect(arg1) {
+  return window['go']['main']['Api']['Reconnect'](arg1);
+}
+
+export function RequestTemplate(arg1, arg2, arg3) {
+  return window['go']['main']['Api']['RequestTemplate'](arg1, arg2, arg3);
+}
+
+export function SaveBinary(arg1) {
+  return window['go']['main']['Api']['SaveBinary'](arg1);
+}
+
+export function SearchSymbols(arg1) {
+  return window['go']['main']['Api']['SearchSymbols'](arg1);
+}
+
 export function Send(arg1) {
   return window['go']['main']['Api']['Send'](arg1);
 }
 
+export function SetProxy(arg1) {
+  return window['go']['main']['Api']['SetProxy'](arg1);
+}
+
 export function Stop(arg1) {
   return window['go']['main']['Api']['Stop'](arg1);
 }
+
+export function SymbolSource(arg1) {
+  return window['go']['main']['Api']['SymbolSource'](arg1);
+}
+
+export function Unwatch(arg1) {
+  return window['go']['main']['Api']['Unwatch'](arg1);
+}
+
+export function VerifyServer(arg1, arg2) {
+  return window['go']['main']['Api']['VerifyServer'](arg1, arg2);
+}
@@ -0,0 +1,303 @@
+package proto
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+)
+
+// Change is a difference between two versions of a schema that breaks existing clients
+// or servers. Wire changes break the binary encoding, Json changes the JSON mapping.
+type Change struct {
+	Element string `json:"element"`
+	Message string `json:"message"`
+	Wire    bool   `json:"wire"`
+	Json    bool   `json:"json"`
+}
+
+// wireGroups are the scalar types whose values can be decoded as one another
+var wireGroups = map[dpb.FieldDescriptorProto_Type]int{
+	dpb.FieldDescriptorProto_TYPE_INT32:    1,
+	dpb.FieldDescriptorProto_TYPE_UINT32:   1,
+	dpb.FieldDescriptorProto_TYPE_INT64:    1,
+	dpb.FieldDescriptorProto_TYPE_UINT64:   1,
+	dpb.FieldDescriptorProto_TYPE_BOOL:     1,
+	dpb.FieldDescriptorProto_TYPE_ENUM:     1,
+	dpb.FieldDescriptorProto_TYPE_SINT32:   2,
+	dpb.FieldDescriptorProto_TYPE_SINT64:   2,
+	dpb.FieldDescriptorProto_TYPE_FIXED32:  3,
+	dpb.FieldDescriptorProto_TYPE_SFIXED32: 3,
+	dpb.FieldDescriptorProto_TYPE_FIXED64:  4,
+	dpb.FieldDescriptorProto_TYPE_SFIXED64: 4,
+	dpb.FieldDescriptorProto_TYPE_STRING:   5,
+	dpb.FieldDescriptorProto_TYPE_BYTES:    5,
+}
+
+// LoadSource parses a .proto file, a descriptor set or buf image, or a directory of protos
+// without registering its services
+func LoadSource(source string, includeDirs []string) ([]*desc.FileDescriptor, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		if IsDescriptorSet(source) {
+			return LoadDescriptorSet(source)
+		}
+		return parseProtos([]string{source}, sourceDirs(source, includeDirs))
+	}
+
+	var names, dirs []string
+	if isBufDir(source) {
+		ws, bufNames, err := bufSources(source)
+		if err != nil {
+			return nil, err
+		}
+		names, dirs = bufNames, ws.IncludeDirs
+	} else {
+		result, dirNames, err := dirSources(source)
+		if err != nil {
+			return nil, err
+		}
+		names, dirs = dirNames, result.IncludeDirs
+	}
+	return parseProtos(names, append(dirs, includeDirs...))
+}
+
+func isBufDir(dir string) bool {
+	for _, name := range []string{"buf.yaml", "buf.work.yaml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare lists the breaking changes from oldFiles to newFiles. Types are only reported
+// as removed when their package still exists, so partial schemas like the files a
+// server returns by reflection can be compared against a whole directory.
+func Compare(oldFiles, newFiles []*desc.FileDescriptor) []Change {
+	c := &comparison{}
+	oldSchema, newSchema := newSchemaIndex(oldFiles), newSchemaIndex(newFiles)
+
+	for name, om := range oldSchema.messages {
+		if nm, ok := newSchema.messages[name]; ok {
+			c.compareMessage(om, nm)
+		} else if newSchema.packages[om.GetFile().GetPackage()] {
+			c.add(name, "message removed", true, true)
+		}
+	}
+	for name, oe := range oldSchema.enums {
+		if ne, ok := newSchema.enums[name]; ok {
+			c.compareEnum(oe, ne)
+		} else if newSchema.packages[oe.GetFile().GetPackage()] {
+			c.add(name, "enum removed", true, true)
+		}
+	}
+	for name, oldService := range oldSchema.services {
+		if newService, ok := newSchema.services[name]; ok {
+			c.compareService(oldService, newService)
+		} else {
+			c.add(name, "service removed", true, true)
+		}
+	}
+
+	sort.Slice(c.changes, func(i, j int) bool {
+		if c.changes[i].Element != c.changes[j].Element {
+			return c.changes[i].Element < c.changes[j].Element
+		}
+		return c.changes[i].Message < c.changes[j].Message
+	})
+	return c.changes
+}
+
+type schemaIndex struct {
+	packages map[string]bool
+	messages map[string]*desc.MessageDescriptor
+	enums    map[string]*desc.EnumDescriptor
+	services map[string]*desc.ServiceDescriptor
+}
+
+func newSchemaIndex(files []*desc.FileDescriptor) *schemaIndex {
+	index := &schemaIndex{
+		packages: map[string]bool{},
+		messages: map[string]*desc.MessageDescriptor{},
+		enums:    map[string]*desc.EnumDescriptor{},
+		services: map[string]*desc.ServiceDescriptor{},
+	}
+	var addMessage func(md *desc.MessageDescriptor)
+	addMessage = func(md *desc.MessageDescriptor) {
+		if md.IsMapEntry() {
+			return
+		}
+		index.messages[md.GetFullyQualifiedName()] = md
+		for _, ed := range md.GetNestedEnumTypes() {
+			index.enums[ed.GetFullyQualifiedName()] = ed
+		}
+		for _, nested := range md.GetNestedMessageTypes() {
+			addMessage(nested)
+		}
+	}
+	for _, fd := range importGraph(files) {
+		index.packages[fd.GetPackage()] = true
+		for _, md := range fd.GetMessageTypes() {
+			addMessage(md)
+		}
+		for _, ed := range fd.GetEnumTypes() {
+			index.enums[ed.GetFullyQualifiedName()] = ed
+		}
+		for _, sd := range fd.GetServices() {
+			index.services[sd.GetFullyQualifiedName()] = sd
+		}
+	}
+	return index
+}
+
+type comparison struct {
+	changes []Change
+}
+
+func (c *comparison) add(element, message string, wire, json bool) {
+	c.changes = append(c.changes, Change{Element: element, Message: message, Wire: wire, Json: json})
+}
+
+func (c *comparison) compareMessage(om, nm *desc.MessageDescriptor) {
+	for _, of := range om.GetFields() {
+		element := of.GetFullyQualifiedName()
+		nf := nm.FindFieldByNumber(of.GetNumber())
+		if nf == nil {
+			if renumbered := nm.FindFieldByName(of.GetName()); renumbered != nil {
+				c.add(element, fmt.Sprintf("field renumbered from %d to %d", of.GetNumber(), renumbered.GetNumber()), true, false)
+				c.compareFieldType(element, of, renumbered)
+			} else {
+				reserved := isReservedNumber(nm.AsDescriptorProto().GetReservedRange(), of.GetNumber())
+				message := fmt.Sprintf("field %d removed", of.GetNumber())
+				if !reserved {
+					message += " without reserving its number"
+				}
+				c.add(element, message, !reserved, true)
+			}
+			continue
+		}
+
+		if nf.GetName() != of.GetName() {
+			c.add(element, fmt.Sprintf("field %d renamed to %s", of.GetNumber(), nf.GetName()), false, true)
+		} else if nf.GetJSONName() != of.GetJSONName() {
+			c.add(element, fmt.Sprintf("json name changed from %s to %s", of.GetJSONName(), nf.GetJSONName()), false, true)
+		}
+		c.compareFieldType(element, of, nf)
+	}
+
+	// new fields must not reuse what the old version reserved
+	reservedNames := map[string]bool{}
+	for _, name := range om.AsDescriptorProto().GetReservedName() {
+		reservedNames[name] = true
+	}
+	for _, nf := range nm.GetFields() {
+		if om.FindFieldByNumber(nf.GetNumber()) != nil {
+			continue
+		}
+		if isReservedNumber(om.AsDescriptorProto().GetReservedRange(), nf.GetNumber()) {
+			c.add(nf.GetFullyQualifiedName(), fmt.Sprintf("field uses number %d reserved in the old version", nf.GetNumber()), true, false)
+		}
+		if reservedNames[nf.GetName()] {
+			c.add(nf.GetFullyQualifiedName(), "field uses a name reserved in the old version", false, true)
+		}
+	}
+}
+
+// compareFieldType reports changes of the type and label of a field kept under a number or name
+func (c *comparison) compareFieldType(element string, of, nf *desc.FieldDescriptor) {
+	if oldType, newType := fieldType(of), fieldType(nf); oldType != newType {
+		wire := true
+		if of.GetMessageType() == nil && nf.GetMessageType() == nil {
+			oldGroup, ok := wireGroups[of.GetType()]
+			wire = !ok || oldGroup != wireGroups[nf.GetType()]
+		}
+		c.add(element, fmt.Sprintf("type changed from %s to %s", oldType, newType), wire, true)
+	}
+	if of.IsRepeated() != nf.IsRepeated() {
+		c.add(element, fmt.Sprintf("label changed from %s to %s", label(of), label(nf)), true, true)
+	}
+}
+
+func (c *comparison) compareEnum(oe, ne *desc.EnumDescriptor) {
+	// unknown values of open proto3 enums survive decoding, closed proto2 enums drop them
+	closed := !oe.GetFile().IsProto3()
+	for _, ov := range oe.GetValues() {
+		element := ov.GetFullyQualifiedName()
+		nv := ne.FindValueByNumber(ov.GetNumber())
+		if nv == nil {
+			c.add(element, fmt.Sprintf("enum value %d removed", ov.GetNumber()), closed, true)
+		} else if nv.GetName() != ov.GetName() {
+			c.add(element, fmt.Sprintf("enum value %d renamed to %s", ov.GetNumber(), nv.GetName()), false, true)
+		}
+	}
+}
+
+func (c *comparison) compareService(oldService, newService *desc.ServiceDescriptor) {
+	for _, om := range oldService.GetMethods() {
+		element := om.GetFullyQualifiedName()
+		nm := newService.FindMethodByName(om.GetName())
+		if nm == nil {
+			c.add(element, "method removed", true, true)
+			continue
+		}
+		if om.GetInputType().GetFullyQualifiedName() != nm.GetInputType().GetFullyQualifiedName() {
+			c.add(element, fmt.Sprintf("request type changed from %s to %s", om.GetInputType().GetFullyQualifiedName(), nm.GetInputType().GetFullyQualifiedName()), true, true)
+		}
+		if om.GetOutputType().GetFullyQualifiedName() != nm.GetOutputType().GetFullyQualifiedName() {
+			c.add(element, fmt.Sprintf("response type changed from %s to %s", om.GetOutputType().GetFullyQualifiedName(), nm.GetOutputType().GetFullyQualifiedName()), true, true)
+		}
+		if om.IsClientStreaming() != nm.IsClientStreaming() || om.IsServerStreaming() != nm.IsServerStreaming() {
+			c.add(element, fmt.Sprintf("streaming changed from %s to %s", streaming(om), streaming(nm)), true, true)
+		}
+	}
+}
+
+func fieldType(fd *desc.FieldDescriptor) string {
+	if fd.IsMap() {
+		return fmt.Sprintf("map<%s, %s>", fieldType(fd.GetMapKeyType()), fieldType(fd.GetMapValueType()))
+	}
+	if md := fd.GetMessageType(); md != nil {
+		return md.GetFullyQualifiedName()
+	}
+	if ed := fd.GetEnumType(); ed != nil {
+		return ed.GetFullyQualifiedName()
+	}
+	return fd.GetType().String()
+}
+
+func label(fd *desc.FieldDescriptor) string {
+	if fd.IsRepeated() {
+		return "repeated"
+	}
+	return "singular"
+}
+
+func streaming(md *desc.MethodDescriptor) string {
+	switch {
+	case md.IsClientStreaming() && md.IsServerStreaming():
+		return "bidirectional streaming"
+	case md.IsClientStreaming():
+		return "client streaming"
+	case md.IsServerStreaming():
+		return "server streaming"
+	default:
+		return "unary"
+	}
+}
+
+func isReservedNumber(ranges []*dpb.DescriptorProto_ReservedRange, number int32) bool {
+	for _, r := range ranges {
+		// reserved range ends are exclusive
+		if number >= r.GetStart() && number < r.GetEnd() {
+			return true
+		}
+	}
+	return false
+}
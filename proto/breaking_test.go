@@ -0,0 +1,54 @@
+package proto
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"v1/api.proto": `syntax = "proto3"; package api;
+service Svc { rpc Get (Req) returns (Res); rpc Watch (Req) returns (stream Res); rpc Drop (Req) returns (Res); }
+message Req { string id = 1; int32 count = 2; string name = 3; repeated string tags = 4; int64 size = 5; reserved 9; }
+message Res { Status status = 1; }
+enum Status { UNKNOWN = 0; OK = 1; FAILED = 2; }
+message Gone {}`,
+		"v2/api.proto": `syntax = "proto3"; package api;
+service Svc { rpc Get (Req) returns (Res); rpc Watch (Req) returns (Res); }
+message Req { string id = 1; int64 count = 2; string title = 3; string tags = 4; string size = 6; int32 legacy = 9; reserved 5; }
+message Res { Status status = 1; }
+enum Status { UNKNOWN = 0; SUCCESS = 1; }`,
+	})
+	oldFiles, err := LoadSource(filepath.Join(dir, "v1/api.proto"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newFiles, err := LoadSource(filepath.Join(dir, "v2/api.proto"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Change{
+		{Element: "api.Gone", Message: "message removed", Wire: true, Json: true},
+		{Element: "api.Req.count", Message: "type changed from TYPE_INT32 to TYPE_INT64", Wire: false, Json: true},
+		{Element: "api.Req.legacy", Message: "field uses number 9 reserved in the old version", Wire: true, Json: false},
+		{Element: "api.Req.name", Message: "field 3 renamed to title", Wire: false, Json: true},
+		{Element: "api.Req.size", Message: "field renumbered from 5 to 6", Wire: true, Json: false},
+		{Element: "api.Req.size", Message: "type changed from TYPE_INT64 to TYPE_STRING", Wire: true, Json: true},
+		{Element: "api.Req.tags", Message: "label changed from repeated to singular", Wire: true, Json: true},
+		{Element: "api.Status.FAILED", Message: "enum value 2 removed", Wire: false, Json: true},
+		{Element: "api.Status.OK", Message: "enum value 1 renamed to SUCCESS", Wire: false, Json: true},
+		{Element: "api.Svc.Drop", Message: "method removed", Wire: true, Json: true},
+		{Element: "api.Svc.Watch", Message: "streaming changed from server streaming to unary", Wire: true, Json: true},
+	}
+	changes := Compare(oldFiles, newFiles)
+	if len(changes) != len(want) {
+		t.Fatalf("got %d changes, want %d: %+v", len(changes), len(want), changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d: got %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+}
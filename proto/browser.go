@@ -0,0 +1,278 @@
+package proto
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoprint"
+	"github.com/pkg/errors"
+)
+
+const (
+	SymbolService   = "service"
+	SymbolMethod    = "method"
+	SymbolMessage   = "message"
+	SymbolEnum      = "enum"
+	SymbolEnumValue = "enumValue"
+	SymbolField     = "field"
+	SymbolExtension = "extension"
+)
+
+// Symbol is an element of a loaded file or one of its imports
+type Symbol struct {
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	FullyName string    `json:"fullyName"`
+	File      string    `json:"file"`
+	Comments  *Comments `json:"comments,omitempty"`
+}
+
+// SymbolDetail describes a symbol one level deep, nested messages are named by Field.Ref
+// and can be described in turn
+type SymbolDetail struct {
+	Symbol
+	Message  *Message  `json:"message,omitempty"`
+	Enum     *Enum     `json:"enum,omitempty"`
+	Field    *Field    `json:"field,omitempty"`
+	Extendee string    `json:"extendee,omitempty"`
+	Methods  []*Method `json:"methods,omitempty"`
+}
+
+// Usage is a field or method referring to a message or enum. Nested usages are methods
+// reaching it through the fields of their input or output.
+type Usage struct {
+	Kind    string `json:"kind"`
+	Element string `json:"element"`
+}
+
+const (
+	UsageField  = "field"
+	UsageInput  = "input"
+	UsageOutput = "output"
+	UsageNested = "nested"
+)
+
+// Symbols lists the elements of every loaded file, only the ones of kind unless it is empty
+func Symbols(kind string) []*Symbol {
+	var symbols []*Symbol
+	for _, fd := range loadedFiles() {
+		eachSymbol(fd, func(symbolKind string, d desc.Descriptor) {
+			if kind == "" || kind == symbolKind {
+				symbols = append(symbols, symbolOf(symbolKind, d))
+			}
+		})
+	}
+	sort.SliceStable(symbols, func(i, j int) bool { return symbols[i].FullyName < symbols[j].FullyName })
+	return symbols
+}
+
+// SearchSymbols finds symbols by a case-insensitive part of their name or comments.
+// Name matches come first.
+func SearchSymbols(query string) []*Symbol {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return []*Symbol{}
+	}
+	var byName, byComment []*Symbol
+	for _, s := range Symbols("") {
+		if strings.Contains(strings.ToLower(s.FullyName), query) {
+			byName = append(byName, s)
+		} else if c := s.Comments; c != nil && strings.Contains(strings.ToLower(c.Leading+"\n"+c.Trailing), query) {
+			byComment = append(byComment, s)
+		}
+	}
+	return append(append([]*Symbol{}, byName...), byComment...)
+}
+
+// DescribeSymbol returns the detail of a symbol by its fully-qualified name
+func DescribeSymbol(fullName string) (*SymbolDetail, error) {
+	d, err := findSymbol(fullName)
+	if err != nil {
+		return nil, err
+	}
+	docs := newDocs()
+	detail := &SymbolDetail{Symbol: *symbolOf(kindOf(d), d)}
+	switch d := d.(type) {
+	case *desc.MessageDescriptor:
+		detail.Message = docs.message(d)
+	case *desc.EnumDescriptor:
+		detail.Enum = enumOf(d)
+	case *desc.FieldDescriptor:
+		detail.Field = docs.field(d)
+		if d.IsExtension() {
+			detail.Extendee = d.GetOwner().GetFullyQualifiedName()
+		}
+	case *desc.ServiceDescriptor:
+		for _, md := range d.GetMethods() {
+			m := &Method{ServiceName: d.GetName(), ServiceFullyName: d.GetFullyQualifiedName(), Name: md.GetName()}
+			docs.describeMethod(m, md)
+			detail.Methods = append(detail.Methods, m)
+		}
+	case *desc.MethodDescriptor:
+		m := &Method{ServiceName: d.GetService().GetName(), ServiceFullyName: d.GetService().GetFullyQualifiedName(), Name: d.GetName()}
+		docs.describeMethod(m, d)
+		detail.Methods = []*Method{m}
+	}
+	return detail, nil
+}
+
+// SymbolSource prints the .proto source of a symbol reconstructed from its descriptor
+func SymbolSource(fullName string) (string, error) {
+	d, err := findSymbol(fullName)
+	if err != nil {
+		return "", err
+	}
+	return (&protoprint.Printer{}).PrintProtoToString(d)
+}
+
+// FindUsages lists the fields and methods using a message or enum
+func FindUsages(fullName string) ([]*Usage, error) {
+	d, err := findSymbol(fullName)
+	if err != nil {
+		return nil, err
+	}
+	switch d.(type) {
+	case *desc.MessageDescriptor, *desc.EnumDescriptor:
+	default:
+		return nil, errors.Errorf("%s is not a message or enum", fullName)
+	}
+
+	usages := []*Usage{}
+	for _, fd := range loadedFiles() {
+		eachSymbol(fd, func(kind string, element desc.Descriptor) {
+			switch e := element.(type) {
+			case *desc.FieldDescriptor:
+				if valueType(e) == fullName {
+					usages = append(usages, &Usage{Kind: UsageField, Element: e.GetFullyQualifiedName()})
+				}
+			case *desc.MethodDescriptor:
+				input, output := e.GetInputType().GetFullyQualifiedName(), e.GetOutputType().GetFullyQualifiedName()
+				switch {
+				case input == fullName:
+					usages = append(usages, &Usage{Kind: UsageInput, Element: e.GetFullyQualifiedName()})
+				case output == fullName:
+					usages = append(usages, &Usage{Kind: UsageOutput, Element: e.GetFullyQualifiedName()})
+				case reaches(e.GetInputType(), fullName, map[string]bool{}) || reaches(e.GetOutputType(), fullName, map[string]bool{}):
+					usages = append(usages, &Usage{Kind: UsageNested, Element: e.GetFullyQualifiedName()})
+				}
+			}
+		})
+	}
+	return usages, nil
+}
+
+// reaches tells whether the fields of md refer to target at any depth
+func reaches(md *desc.MessageDescriptor, target string, seen map[string]bool) bool {
+	if seen[md.GetFullyQualifiedName()] {
+		return false
+	}
+	seen[md.GetFullyQualifiedName()] = true
+	for _, fd := range md.GetFields() {
+		if valueType(fd) == target {
+			return true
+		}
+		value := fd
+		if fd.IsMap() {
+			value = fd.GetMapValueType()
+		}
+		if vm := value.GetMessageType(); vm != nil && reaches(vm, target, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// valueType is the message or enum a field holds, map values included
+func valueType(fd *desc.FieldDescriptor) string {
+	if fd.IsMap() {
+		fd = fd.GetMapValueType()
+	}
+	if md := fd.GetMessageType(); md != nil {
+		return md.GetFullyQualifiedName()
+	}
+	if ed := fd.GetEnumType(); ed != nil {
+		return ed.GetFullyQualifiedName()
+	}
+	return ""
+}
+
+func findSymbol(fullName string) (desc.Descriptor, error) {
+	for _, fd := range loadedFiles() {
+		if d := fd.FindSymbol(fullName); d != nil {
+			return d, nil
+		}
+	}
+	return nil, errors.Errorf("symbol %s not found", fullName)
+}
+
+func symbolOf(kind string, d desc.Descriptor) *Symbol {
+	return &Symbol{Kind: kind, Name: d.GetName(), FullyName: d.GetFullyQualifiedName(), File: d.GetFile().GetName(), Comments: commentsOf(d)}
+}
+
+func kindOf(d desc.Descriptor) string {
+	switch d := d.(type) {
+	case *desc.ServiceDescriptor:
+		return SymbolService
+	case *desc.MethodDescriptor:
+		return SymbolMethod
+	case *desc.MessageDescriptor:
+		return SymbolMessage
+	case *desc.EnumDescriptor:
+		return SymbolEnum
+	case *desc.EnumValueDescriptor:
+		return SymbolEnumValue
+	case *desc.FieldDescriptor:
+		if d.IsExtension() {
+			return SymbolExtension
+		}
+		return SymbolField
+	}
+	return ""
+}
+
+// eachSymbol visits the elements declared in fd, map entries are left out
+func eachSymbol(fd *desc.FileDescriptor, f func(kind string, d desc.Descriptor)) {
+	var visitEnum func(ed *desc.EnumDescriptor)
+	visitEnum = func(ed *desc.EnumDescriptor) {
+		f(SymbolEnum, ed)
+		for _, vd := range ed.GetValues() {
+			f(SymbolEnumValue, vd)
+		}
+	}
+	var visitMessage func(md *desc.MessageDescriptor)
+	visitMessage = func(md *desc.MessageDescriptor) {
+		if md.IsMapEntry() {
+			return
+		}
+		f(SymbolMessage, md)
+		for _, field := range md.GetFields() {
+			f(SymbolField, field)
+		}
+		for _, ext := range md.GetNestedExtensions() {
+			f(SymbolExtension, ext)
+		}
+		for _, ed := range md.GetNestedEnumTypes() {
+			visitEnum(ed)
+		}
+		for _, nested := range md.GetNestedMessageTypes() {
+			visitMessage(nested)
+		}
+	}
+
+	for _, sd := range fd.GetServices() {
+		f(SymbolService, sd)
+		for _, md := range sd.GetMethods() {
+			f(SymbolMethod, md)
+		}
+	}
+	for _, md := range fd.GetMessageTypes() {
+		visitMessage(md)
+	}
+	for _, ed := range fd.GetEnumTypes() {
+		visitEnum(ed)
+	}
+	for _, ext := range fd.GetExtensions() {
+		f(SymbolExtension, ext)
+	}
+}
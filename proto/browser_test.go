@@ -0,0 +1,102 @@
+package proto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBrowser(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"shop.proto": `syntax = "proto3"; package shop;
+// A priced amount
+message Money { int64 cents = 1; }
+message Item { string sku = 1; Money price = 2; }
+message Order { repeated Item items = 1; map<string, Money> fees = 2; }
+message Total { Money sum = 1; }
+service Shop {
+  rpc Place (Order) returns (Total);
+  rpc Price (Money) returns (Money);
+}`,
+	})
+	if _, err := Parse([]string{dir + "/shop.proto"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if messages := Symbols(SymbolMessage); len(messages) < 4 {
+		t.Fatalf("messages are missing: %+v", messages)
+	}
+	if found := SearchSymbols("priced"); len(found) != 1 || found[0].FullyName != "shop.Money" {
+		t.Fatalf("comment search: %+v", found)
+	}
+
+	detail, err := DescribeSymbol("shop.Item")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price := detail.Message.Fields[1]; price.Type != "shop.Money" || price.Ref != "shop.Money" {
+		t.Fatalf("nested messages should only be named: %+v", price)
+	}
+
+	usages, err := FindUsages("shop.Money")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]string{}
+	for _, u := range usages {
+		kinds[u.Element] = u.Kind
+	}
+	if kinds["shop.Item.price"] != UsageField || kinds["shop.Order.fees"] != UsageField ||
+		kinds["shop.Shop.Place"] != UsageNested || kinds["shop.Shop.Price"] != UsageInput {
+		t.Fatalf("usages: %+v", kinds)
+	}
+
+	source, err := SymbolSource("shop.Money")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(source, "message Money") || !strings.Contains(source, "A priced amount") {
+		t.Fatalf("source: %s", source)
+	}
+	if _, err := SymbolSource("shop.Nope"); err == nil {
+		t.Fatal("unknown symbol should fail")
+	}
+}
+
+func TestBrowserDropsRemovedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app.proto":  `syntax = "proto3"; package gone; import "kept.proto"; service App { rpc Run (Kept) returns (Kept); }`,
+		"kept.proto": `syntax = "proto3"; package gone; message Kept {}`,
+	})
+	appPath, keptPath := dir+"/app.proto", dir+"/kept.proto"
+	count := func(fullName string) int {
+		n := 0
+		for _, s := range SearchSymbols(fullName) {
+			if s.FullyName == fullName {
+				n++
+			}
+		}
+		return n
+	}
+
+	// kept.proto is imported by name and loaded by its absolute path
+	if _, err := Parse([]string{appPath, keptPath}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if count("gone.Kept") != 1 || count("gone.App") != 1 {
+		t.Fatalf("want each file once: %+v", SearchSymbols("gone."))
+	}
+
+	unregister(appPath)
+	if count("gone.App") != 0 || count("gone.Kept") != 1 || FindService("gone.App") != nil {
+		t.Fatalf("want only the elements of kept.proto: %+v", SearchSymbols("gone."))
+	}
+	unregister(keptPath)
+	if count("gone.Kept") != 0 {
+		t.Fatalf("orphaned import is still loaded: %+v", SearchSymbols("gone."))
+	}
+	if _, err := FindUsages("gone.Kept"); err == nil {
+		t.Fatal("usages of a removed message should not be found")
+	}
+}
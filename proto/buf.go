@@ -0,0 +1,304 @@
+package proto
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"uprpc/pkg/file"
+
+	osruntime "runtime"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Workspace is a directory of proto sources parsed together with the include dirs
+// needed to invoke its methods
+type Workspace struct {
+	Dir         string   `json:"dir"`
+	IncludeDirs []string `json:"includeDirs"`
+	Files       []*File  `json:"files"`
+	// Missing lists dependencies that are not available locally
+	Missing []string `json:"missing,omitempty"`
+}
+
+// bufConfig is a buf.yaml, v1 describes the module in its directory and v2 lists modules
+type bufConfig struct {
+	Version string   `yaml:"version"`
+	Deps    []string `yaml:"deps"`
+	Build   struct {
+		Excludes []string `yaml:"excludes"`
+	} `yaml:"build"`
+	Modules []struct {
+		Path     string   `yaml:"path"`
+		Excludes []string `yaml:"excludes"`
+	} `yaml:"modules"`
+}
+
+type bufWork struct {
+	Directories []string `yaml:"directories"`
+}
+
+type bufLock struct {
+	Deps []struct {
+		// v1 locks split the module name, v2 locks use name
+		Remote     string `yaml:"remote"`
+		Owner      string `yaml:"owner"`
+		Repository string `yaml:"repository"`
+		Name       string `yaml:"name"`
+		Commit     string `yaml:"commit"`
+	} `yaml:"deps"`
+}
+
+type bufModule struct {
+	root     string
+	excludes []string
+}
+
+// ParseBuf parses every proto of the buf workspace or module in dir. The module roots
+// are the include dirs, dependencies of buf.lock are resolved from the local buf cache.
+func ParseBuf(dir string) (*Workspace, error) {
+	ws, names, err := bufSources(dir)
+	if err != nil {
+		return nil, err
+	}
+	if ws.Files, err = parseSources(ws.Dir, names, ws.IncludeDirs); err != nil {
+		return nil, err
+	}
+	var methods []*Method
+	for _, file := range ws.Files {
+		methods = append(methods, file.Methods...)
+	}
+	registerMethods(ws.Dir, methods)
+	return ws, nil
+}
+
+// bufSources returns the workspace in dir without files and the names of its protos
+func bufSources(dir string) (*Workspace, []string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	modules, lockDirs, err := bufModules(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ws := &Workspace{Dir: dir}
+	for _, module := range modules {
+		ws.IncludeDirs = append(ws.IncludeDirs, module.root)
+	}
+	for _, lockDir := range lockDirs {
+		deps, missing, err := bufLockDeps(lockDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		ws.IncludeDirs = append(ws.IncludeDirs, deps...)
+		ws.Missing = append(ws.Missing, missing...)
+	}
+
+	var names []string
+	for _, module := range modules {
+		found, err := findProtos(module.root, module.excludes)
+		if err != nil {
+			return nil, nil, err
+		}
+		names = append(names, found...)
+	}
+	if len(names) == 0 {
+		return nil, nil, errors.Errorf("no proto files found in %s", dir)
+	}
+	return ws, names, nil
+}
+
+// bufModules returns the modules of the workspace in dir and the directories holding their buf.lock
+func bufModules(dir string) ([]bufModule, []string, error) {
+	var work bufWork
+	if ok, err := readYaml(filepath.Join(dir, "buf.work.yaml"), &work); err != nil {
+		return nil, nil, err
+	} else if ok {
+		var modules []bufModule
+		for _, d := range work.Directories {
+			root := filepath.Join(dir, d)
+			var config bufConfig
+			if _, err := readYaml(filepath.Join(root, "buf.yaml"), &config); err != nil {
+				return nil, nil, err
+			}
+			modules = append(modules, bufModule{root: root, excludes: relativeExcludes(root, root, config.Build.Excludes)})
+		}
+		// every module of a v1 workspace has its own buf.lock
+		var lockDirs []string
+		for _, module := range modules {
+			lockDirs = append(lockDirs, module.root)
+		}
+		return modules, lockDirs, nil
+	}
+
+	var config bufConfig
+	if ok, err := readYaml(filepath.Join(dir, "buf.yaml"), &config); err != nil {
+		return nil, nil, err
+	} else if !ok {
+		return nil, nil, errors.Errorf("no buf.yaml or buf.work.yaml in %s", dir)
+	}
+	if config.Version != "v2" {
+		return []bufModule{{root: dir, excludes: relativeExcludes(dir, dir, config.Build.Excludes)}}, []string{dir}, nil
+	}
+	var modules []bufModule
+	for _, m := range config.Modules {
+		root := filepath.Join(dir, m.Path)
+		modules = append(modules, bufModule{root: root, excludes: relativeExcludes(dir, root, m.Excludes)})
+	}
+	if len(modules) == 0 {
+		modules = append(modules, bufModule{root: dir})
+	}
+	return modules, []string{dir}, nil
+}
+
+// relativeExcludes converts excludes relative to base into paths relative to root
+func relativeExcludes(base, root string, excludes []string) []string {
+	var rel []string
+	for _, exclude := range excludes {
+		if r, err := filepath.Rel(root, filepath.Join(base, exclude)); err == nil {
+			rel = append(rel, filepath.ToSlash(r))
+		}
+	}
+	return rel
+}
+
+// bufLockDeps resolves the dependencies of the buf.lock in dir to module directories of
+// the buf cache, returning the names of those not downloaded yet
+func bufLockDeps(dir string) ([]string, []string, error) {
+	var lock bufLock
+	if ok, err := readYaml(filepath.Join(dir, "buf.lock"), &lock); err != nil || !ok {
+		return nil, nil, err
+	}
+	cache := bufCacheDir()
+	var deps, missing []string
+	for _, dep := range lock.Deps {
+		name := dep.Name
+		if name == "" {
+			name = path.Join(dep.Remote, dep.Owner, dep.Repository)
+		}
+		candidates := []string{
+			filepath.Join(cache, "v1", "module", "data", filepath.FromSlash(name), dep.Commit),
+			filepath.Join(cache, "v3", "modules", "b5", filepath.FromSlash(name), dep.Commit, "files"),
+		}
+		found := false
+		for _, candidate := range candidates {
+			if ok, _ := file.ExistPath(candidate); ok {
+				deps = append(deps, candidate)
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, name+":"+dep.Commit)
+		}
+	}
+	return deps, missing, nil
+}
+
+func bufCacheDir() string {
+	if dir := os.Getenv("BUF_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "buf")
+}
+
+func readYaml(fileName string, v interface{}) (bool, error) {
+	b, err := os.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := yaml.Unmarshal(b, v); err != nil {
+		return false, errors.Wrapf(err, "parse %s", fileName)
+	}
+	return true, nil
+}
+
+// findProtos returns the .proto files below root relative to it, skipping the excluded dirs
+func findProtos(root string, excludes []string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if contains(excludes, rel) || (rel != "." && strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(d.Name(), ".proto") {
+			names = append(names, rel)
+		}
+		return nil
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+// parseSources parses proto files given relative to includeDirs together, returning
+// a File for each file declaring services
+func parseSources(owner string, names, includeDirs []string) ([]*File, error) {
+	fileDescs, err := parseDescs(owner, names, includeDirs)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []*File{}
+	for i, fd := range fileDescs {
+		if len(fd.GetServices()) == 0 {
+			continue
+		}
+		protoPath := lookupFile(names[i], includeDirs)
+		if osruntime.GOOS == "windows" {
+			protoPath = filepath.ToSlash(protoPath)
+		}
+		file := &File{Id: uuid.NewV4().String(), Host: "127.0.0.1:9000", Name: path.Base(protoPath), Path: protoPath}
+		file.Methods = parseMethod(fd.GetServices(), docsInto(&file.Messages))
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func parseDescs(owner string, names, includeDirs []string) ([]*desc.FileDescriptor, error) {
+	fileDescs, err := parseProtos(names, includeDirs)
+	if err != nil {
+		return nil, err
+	}
+	register(owner, fileDescs, includeDirs)
+	return fileDescs, nil
+}
+
+// parseProtos parses without registering the services, for versions that are not invoked
+func parseProtos(names, includeDirs []string) ([]*desc.FileDescriptor, error) {
+	logrus.Debugf("parse proto files: %+v, include dirs: %+v", names, includeDirs)
+	parser := protoparse.Parser{Accessor: accessor(includeDirs), IncludeSourceCodeInfo: true}
+	return parser.ParseFiles(names...)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package proto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseBuf(t *testing.T) {
+	dir, cache := t.TempDir(), t.TempDir()
+	t.Setenv("BUF_CACHE_DIR", cache)
+	writeFiles(t, cache, map[string]string{
+		"v1/module/data/buf.build/acme/types/c1/acme/types/money.proto": `syntax = "proto3"; package acme.types; message Money { int64 units = 1; }`,
+	})
+	writeFiles(t, dir, map[string]string{
+		"buf.work.yaml":     "version: v1\ndirectories:\n  - api\n",
+		"api/buf.yaml":      "version: v1\nbuild:\n  excludes:\n    - tmp\n",
+		"api/buf.lock":      "version: v1\ndeps:\n  - remote: buf.build\n    owner: acme\n    repository: types\n    commit: c1\n  - remote: buf.build\n    owner: acme\n    repository: gone\n    commit: c2\n",
+		"api/tmp/bad.proto": `not a proto`,
+		"api/shop/v1/order.proto": `syntax = "proto3"; package shop.v1;
+import "acme/types/money.proto";
+import "shop/v1/common.proto";
+import "google/api/annotations.proto";
+service Orders { rpc Get (Id) returns (acme.types.Money) { option (google.api.http) = { get: "/v1/orders/{id}" }; } }`,
+		"api/shop/v1/common.proto": `syntax = "proto3"; package shop.v1; message Id { string id = 1; }`,
+	})
+
+	ws, err := ParseBuf(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ws.Files) != 1 || ws.Files[0].Path != filepath.ToSlash(filepath.Join(dir, "api/shop/v1/order.proto")) {
+		t.Fatalf("unexpected files %+v", ws.Files)
+	}
+	if len(ws.IncludeDirs) != 2 || len(ws.Missing) != 1 || ws.Missing[0] != "buf.build/acme/gone:c2" {
+		t.Fatalf("unexpected include dirs %v, missing %v", ws.IncludeDirs, ws.Missing)
+	}
+	if ws.Files[0].Methods[0].Http == nil {
+		t.Fatal("http rule is lost")
+	}
+}
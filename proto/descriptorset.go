@@ -0,0 +1,70 @@
+package proto
+
+import (
+	"bytes"
+	"os"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// IsDescriptorSet reports whether fileName is a compiled FileDescriptorSet rather than a
+// .proto source, like protoc --descriptor_set_out or grpcurl -protoset files. Buf images are
+// wire compatible with FileDescriptorSet, so `buf build -o image.binpb` output is one too.
+// Extensions like .pb and .bin are shared with encoded messages, so the content decides:
+// it has to decode as a set of named files.
+func IsDescriptorSet(fileName string) bool {
+	if strings.HasSuffix(fileName, ".proto") {
+		return false
+	}
+	set, err := readDescriptorSet(fileName)
+	if err != nil || len(set.GetFile()) == 0 {
+		return false
+	}
+	for _, fd := range set.GetFile() {
+		if fd.GetName() == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadDescriptorSet reads a binary or JSON encoded FileDescriptorSet and links its files.
+// The set must contain every import, build it with --include_imports.
+func LoadDescriptorSet(fileName string) ([]*desc.FileDescriptor, error) {
+	set, err := readDescriptorSet(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	linked, err := desc.CreateFileDescriptorsFromSet(set)
+	if err != nil {
+		return nil, errors.Wrapf(err, "link descriptor set %s, was it built with --include_imports?", fileName)
+	}
+	files := make([]*desc.FileDescriptor, 0, len(set.GetFile()))
+	for _, fd := range set.GetFile() {
+		files = append(files, linked[fd.GetName()])
+	}
+	return files, nil
+}
+
+func readDescriptorSet(fileName string) (*dpb.FileDescriptorSet, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var set dpb.FileDescriptorSet
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(b, &set)
+	} else {
+		err = proto.Unmarshal(b, &set)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "decode descriptor set %s", fileName)
+	}
+	return &set, nil
+}
@@ -0,0 +1,57 @@
+package proto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestParseDescriptorSet(t *testing.T) {
+	protoPath := "../test/library.proto"
+	fds, err := (&protoparse.Parser{Accessor: Accessor(protoPath, nil)}).ParseFiles(protoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := desc.ToFileDescriptorSet(fds...)
+	binary, _ := proto.Marshal(set)
+	json, _ := protojson.Marshal(proto.MessageV2(set))
+
+	dir := t.TempDir()
+	for name, b := range map[string][]byte{"library.protoset": binary, "library.protoset.json": json, "library.bin": binary} {
+		setPath := filepath.Join(dir, name)
+		if err := os.WriteFile(setPath, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files, err := Parse([]string{setPath}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 1 || len(files[0].Methods) != 5 {
+			t.Fatalf("%s: unexpected files %+v", name, files)
+		}
+		if files[0].Methods[0].Http == nil {
+			t.Fatalf("%s: http rule of %s is lost", name, files[0].Methods[0].Name)
+		}
+		if FindService("library.Library") == nil {
+			t.Fatalf("%s: service is not registered", name)
+		}
+	}
+
+	// an encoded request body shares the extension but is no descriptor set
+	body, _ := proto.Marshal(&dpb.FieldOptions{Deprecated: proto.Bool(true)})
+	for name, b := range map[string][]byte{"body.pb": body, "empty.bin": nil, "text.pb": []byte("hi"), "unnamed.bin": {0x0a, 0x02, 0x68, 0x69}} {
+		bodyPath := filepath.Join(dir, name)
+		if err := os.WriteFile(bodyPath, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if IsDescriptorSet(bodyPath) {
+			t.Fatalf("%s should not be taken for a descriptor set", name)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package proto
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	protoComment = regexp.MustCompile(`(?s)/\*.*?\*/|//[^\n]*`)
+	protoImport  = regexp.MustCompile(`(?m)(?:^|;)\s*import\s+(?:public\s+|weak\s+)?"([^"]+)"\s*;`)
+	protoPackage = regexp.MustCompile(`(?m)(?:^|;)\s*package\s+([\w.]+)\s*;`)
+)
+
+// DirImport is a directory parsed recursively with the include dirs inferred from its imports
+type DirImport struct {
+	Dir         string     `json:"dir"`
+	IncludeDirs []string   `json:"includeDirs"`
+	Packages    []*Package `json:"packages"`
+	Unresolved  []Import   `json:"unresolved,omitempty"`
+}
+
+// Package holds the methods of every service declared in a proto package
+type Package struct {
+	Id      string    `json:"id"`
+	Host    string    `json:"host"`
+	Name    string    `json:"name"`
+	Methods []*Method `json:"methods"`
+	// Messages describes the inputs and outputs of the methods, see File.Messages
+	Messages map[string]*Message `json:"messages,omitempty"`
+}
+
+// Import is an import statement of File that could not be resolved
+type Import struct {
+	File   string `json:"file"`
+	Import string `json:"import"`
+}
+
+type protoSource struct {
+	path    string
+	pkg     string
+	imports []string
+	// name is the path the file is imported by, relative to one of the include dirs
+	name string
+}
+
+// ImportDir parses every .proto below dir. Include dirs are inferred from the imports
+// that resolve to files of dir, files with unresolved imports are left out and reported.
+func ImportDir(dir string) (*DirImport, error) {
+	result, names, err := dirSources(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return result, nil
+	}
+	fileDescs, err := parseDescs(result.Dir, names, result.IncludeDirs)
+	if err != nil {
+		return nil, err
+	}
+
+	packages := map[string]*Package{}
+	for _, fd := range fileDescs {
+		if len(fd.GetServices()) == 0 {
+			continue
+		}
+		pkg, ok := packages[fd.GetPackage()]
+		if !ok {
+			pkg = &Package{Id: uuid.NewV4().String(), Host: "127.0.0.1:9000", Name: fd.GetPackage(), Methods: []*Method{}}
+			packages[fd.GetPackage()] = pkg
+			result.Packages = append(result.Packages, pkg)
+		}
+		pkg.Methods = append(pkg.Methods, parseMethod(fd.GetServices(), docsInto(&pkg.Messages))...)
+	}
+	sort.Slice(result.Packages, func(i, j int) bool { return result.Packages[i].Name < result.Packages[j].Name })
+	var methods []*Method
+	for _, pkg := range result.Packages {
+		methods = append(methods, pkg.Methods...)
+	}
+	registerMethods(result.Dir, methods)
+	return result, nil
+}
+
+// dirSources infers the include dirs of the protos below dir, returning the names of
+// the files that can be parsed with them
+func dirSources(dir string) (*DirImport, []string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	names, err := findProtos(dir, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(names) == 0 {
+		return nil, nil, errors.Errorf("no proto files found in %s", dir)
+	}
+
+	sources := make([]*protoSource, 0, len(names))
+	for _, name := range names {
+		src, err := scanSource(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, nil, err
+		}
+		sources = append(sources, src)
+	}
+
+	result := &DirImport{Dir: dir}
+	roots := map[string]bool{}
+	resolved := map[string]*protoSource{}
+	for _, src := range sources {
+		for _, imp := range src.imports {
+			target := resolveImport(sources, imp)
+			if target == nil {
+				if !bundled(imp) {
+					result.Unresolved = append(result.Unresolved, Import{File: src.path, Import: imp})
+				}
+				continue
+			}
+			resolved[imp] = target
+			target.name = imp
+			roots[strings.TrimSuffix(target.path, "/"+imp)] = true
+		}
+	}
+	// files nobody imports are named after their package directories when they match
+	for _, src := range sources {
+		if src.name != "" {
+			continue
+		}
+		if pkgDir := strings.ReplaceAll(src.pkg, ".", "/"); pkgDir != "" && strings.Contains(src.path, "/"+pkgDir+"/") {
+			src.name = src.path[strings.LastIndex(src.path, "/"+pkgDir+"/")+1:]
+			roots[strings.TrimSuffix(src.path, "/"+src.name)] = true
+		} else {
+			src.name = strings.TrimPrefix(src.path, filepath.ToSlash(dir)+"/")
+			roots[filepath.ToSlash(dir)] = true
+		}
+	}
+	for root := range roots {
+		result.IncludeDirs = append(result.IncludeDirs, filepath.FromSlash(root))
+	}
+	sort.Strings(result.IncludeDirs)
+
+	broken := brokenSources(sources, resolved)
+	var parseNames []string
+	for _, src := range sources {
+		if !broken[src] {
+			parseNames = append(parseNames, src.name)
+		}
+	}
+	return result, parseNames, nil
+}
+
+func scanSource(fileName string) (*protoSource, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	content := protoComment.ReplaceAllString(string(b), "")
+	src := &protoSource{path: filepath.ToSlash(fileName)}
+	if match := protoPackage.FindStringSubmatch(content); match != nil {
+		src.pkg = match[1]
+	}
+	for _, match := range protoImport.FindAllStringSubmatch(content, -1) {
+		src.imports = append(src.imports, match[1])
+	}
+	return src, nil
+}
+
+// resolveImport returns the source whose path ends with the import, preferring the shortest path
+func resolveImport(sources []*protoSource, imp string) *protoSource {
+	var found *protoSource
+	for _, src := range sources {
+		if src.path == imp || strings.HasSuffix(src.path, "/"+imp) {
+			if found == nil || len(src.path) < len(found.path) {
+				found = src
+			}
+		}
+	}
+	return found
+}
+
+// bundled reports whether an import is provided without being on disk, like the well-known types
+func bundled(imp string) bool {
+	if strings.HasPrefix(imp, "google/protobuf/") {
+		return true
+	}
+	f, err := includeFS.Open("include/" + imp)
+	if err != nil {
+		return false
+	}
+	_ = f.Close()
+	return true
+}
+
+// brokenSources returns the sources that have an unresolved import, directly or through their imports
+func brokenSources(sources []*protoSource, resolved map[string]*protoSource) map[*protoSource]bool {
+	broken := map[*protoSource]bool{}
+	for changed := true; changed; {
+		changed = false
+		for _, src := range sources {
+			if broken[src] {
+				continue
+			}
+			for _, imp := range src.imports {
+				target, ok := resolved[imp]
+				if (!ok && !bundled(imp)) || (ok && broken[target]) {
+					broken[src] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return broken
+}
@@ -0,0 +1,40 @@
+package proto
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestImportDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a/protos/shop/v1/order.proto": `syntax = "proto3"; package shop.v1;
+import "shop/v1/common.proto"; // the id
+import "google/api/annotations.proto";
+service Orders { rpc Get (Id) returns (Id); }`,
+		"a/protos/shop/v1/common.proto": `syntax = "proto3"; package shop.v1; message Id { string id = 1; }`,
+		"d/shop/v1/extra.proto":         `syntax = "proto3"; package shop.v1; import "google/protobuf/empty.proto"; service Extra { rpc Ping (google.protobuf.Empty) returns (google.protobuf.Empty); }`,
+		"b/billing.proto":               `syntax = "proto3"; package billing; message M {} service Billing { rpc Pay (M) returns (M); }`,
+		"c/broken.proto":                `syntax = "proto3"; package broken; import "missing/x.proto"; service Broken { rpc X (X) returns (X); }`,
+	})
+
+	result, err := ImportDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Unresolved) != 1 || result.Unresolved[0].Import != "missing/x.proto" {
+		t.Fatalf("unexpected unresolved imports %+v", result.Unresolved)
+	}
+	wantDirs := []string{dir, filepath.Join(dir, "a/protos"), filepath.Join(dir, "d")}
+	if len(result.IncludeDirs) != len(wantDirs) {
+		t.Fatalf("unexpected include dirs %v", result.IncludeDirs)
+	}
+	for i, d := range wantDirs {
+		if result.IncludeDirs[i] != d {
+			t.Fatalf("unexpected include dirs %v", result.IncludeDirs)
+		}
+	}
+	if len(result.Packages) != 2 || result.Packages[0].Name != "billing" || result.Packages[1].Name != "shop.v1" || len(result.Packages[1].Methods) != 2 {
+		t.Fatalf("unexpected packages %+v", result.Packages)
+	}
+}
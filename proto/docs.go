@@ -0,0 +1,229 @@
+package proto
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+// Comments are the comments attached to an element in its .proto source
+type Comments struct {
+	Leading  string `json:"leading,omitempty"`
+	Trailing string `json:"trailing,omitempty"`
+}
+
+// Message is the structure of a message type shown as documentation next to the request.
+// Fields refer to the messages they hold by name, see File.Messages.
+type Message struct {
+	Name       string                 `json:"name"`
+	FullyName  string                 `json:"fullyName"`
+	Comments   *Comments              `json:"comments,omitempty"`
+	Deprecated bool                   `json:"deprecated,omitempty"`
+	Options    map[string]interface{} `json:"options,omitempty"`
+	Fields     []*Field               `json:"fields,omitempty"`
+}
+
+type Field struct {
+	Name       string                 `json:"name"`
+	JsonName   string                 `json:"jsonName"`
+	Number     int32                  `json:"number"`
+	Type       string                 `json:"type"`
+	Label      string                 `json:"label"`
+	OneOf      string                 `json:"oneOf,omitempty"`
+	Comments   *Comments              `json:"comments,omitempty"`
+	Deprecated bool                   `json:"deprecated,omitempty"`
+	Options    map[string]interface{} `json:"options,omitempty"`
+	// Ref is the full name of the message held, the value of a map
+	Ref  string `json:"ref,omitempty"`
+	Enum *Enum  `json:"enum,omitempty"`
+}
+
+type Enum struct {
+	Name       string       `json:"name"`
+	FullyName  string       `json:"fullyName"`
+	Comments   *Comments    `json:"comments,omitempty"`
+	Deprecated bool         `json:"deprecated,omitempty"`
+	Values     []*EnumValue `json:"values"`
+}
+
+type EnumValue struct {
+	Name       string    `json:"name"`
+	Number     int32     `json:"number"`
+	Comments   *Comments `json:"comments,omitempty"`
+	Deprecated bool      `json:"deprecated,omitempty"`
+}
+
+// docs builds the documentation of elements, extension registries are cached per file.
+// The messages reachable from described methods are collected once each in messages.
+type docs struct {
+	registries map[string]*dynamic.ExtensionRegistry
+	messages   map[string]*Message
+}
+
+func newDocs() *docs {
+	return &docs{registries: map[string]*dynamic.ExtensionRegistry{}, messages: map[string]*Message{}}
+}
+
+// docsInto collects the messages of the methods it describes into the table of a File or Package
+func docsInto(messages *map[string]*Message) *docs {
+	if *messages == nil {
+		*messages = map[string]*Message{}
+	}
+	d := newDocs()
+	d.messages = *messages
+	return d
+}
+
+// describeMethod fills the comments, options and message trees of m
+func (d *docs) describeMethod(m *Method, method *desc.MethodDescriptor) {
+	m.Comments = commentsOf(method)
+	m.ServiceComments = commentsOf(method.GetService())
+	m.Deprecated = method.GetMethodOptions().GetDeprecated()
+	if level := method.GetMethodOptions().GetIdempotencyLevel(); level != dpb.MethodOptions_IDEMPOTENCY_UNKNOWN {
+		m.IdempotencyLevel = level.String()
+	}
+	m.Options = d.options(method)
+	m.ServiceOptions = d.options(method.GetService())
+	m.Input = d.collect(method.GetInputType())
+	m.Output = d.collect(method.GetOutputType())
+}
+
+// collect adds md and the messages its fields hold to the table and returns its name
+func (d *docs) collect(md *desc.MessageDescriptor) string {
+	name := md.GetFullyQualifiedName()
+	if _, ok := d.messages[name]; ok {
+		return name
+	}
+	d.messages[name] = d.message(md)
+	for _, fd := range md.GetFields() {
+		if fd.IsMap() {
+			fd = fd.GetMapValueType()
+		}
+		if vm := fd.GetMessageType(); vm != nil {
+			d.collect(vm)
+		}
+	}
+	return name
+}
+
+func (d *docs) message(md *desc.MessageDescriptor) *Message {
+	m := &Message{
+		Name:       md.GetName(),
+		FullyName:  md.GetFullyQualifiedName(),
+		Comments:   commentsOf(md),
+		Deprecated: md.GetMessageOptions().GetDeprecated(),
+		Options:    d.options(md),
+	}
+	for _, fd := range md.GetFields() {
+		m.Fields = append(m.Fields, d.field(fd))
+	}
+	return m
+}
+
+func (d *docs) field(fd *desc.FieldDescriptor) *Field {
+	f := &Field{
+		Name:       fd.GetName(),
+		JsonName:   fd.GetJSONName(),
+		Number:     fd.GetNumber(),
+		Type:       fieldType(fd),
+		Label:      strings.ToLower(strings.TrimPrefix(fd.GetLabel().String(), "LABEL_")),
+		Comments:   commentsOf(fd),
+		Deprecated: fd.GetFieldOptions().GetDeprecated(),
+		Options:    d.options(fd),
+	}
+	if oneOf := fd.GetOneOf(); oneOf != nil && !oneOf.IsSynthetic() {
+		f.OneOf = oneOf.GetName()
+	}
+	value := fd
+	if fd.IsMap() {
+		f.Label = "map"
+		value = fd.GetMapValueType()
+	}
+	if vm := value.GetMessageType(); vm != nil {
+		f.Ref = vm.GetFullyQualifiedName()
+	}
+	if ve := value.GetEnumType(); ve != nil {
+		f.Enum = enumOf(ve)
+	}
+	return f
+}
+
+func enumOf(ed *desc.EnumDescriptor) *Enum {
+	e := &Enum{
+		Name:       ed.GetName(),
+		FullyName:  ed.GetFullyQualifiedName(),
+		Comments:   commentsOf(ed),
+		Deprecated: ed.GetEnumOptions().GetDeprecated(),
+	}
+	for _, vd := range ed.GetValues() {
+		e.Values = append(e.Values, &EnumValue{
+			Name:       vd.GetName(),
+			Number:     vd.GetNumber(),
+			Comments:   commentsOf(vd),
+			Deprecated: vd.GetEnumValueOptions().GetDeprecated(),
+		})
+	}
+	return e
+}
+
+// options renders the options set on an element as protojson, custom options are keyed
+// by their bracketed full name like "[google.api.http]"
+func (d *docs) options(element desc.Descriptor) map[string]interface{} {
+	opts := element.GetOptions()
+	if opts == nil || proto.Size(opts) == 0 {
+		return nil
+	}
+	optsDesc, err := desc.LoadMessageDescriptorForMessage(opts)
+	if err != nil {
+		return nil
+	}
+	msg := dynamic.NewMessageFactoryWithExtensionRegistry(d.registry(element.GetFile())).NewDynamicMessage(optsDesc)
+	if err := msg.ConvertFrom(opts); err != nil {
+		return nil
+	}
+	b, err := msg.MarshalJSONPB(&jsonpb.Marshaler{OrigName: true})
+	if err != nil {
+		return nil
+	}
+	var options map[string]interface{}
+	if err := json.Unmarshal(b, &options); err != nil || len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+func (d *docs) registry(fd *desc.FileDescriptor) *dynamic.ExtensionRegistry {
+	if er, ok := d.registries[fd.GetName()]; ok {
+		return er
+	}
+	er := dynamic.NewExtensionRegistryWithDefaults()
+	er.AddExtensionsFromFileRecursively(fd)
+	d.registries[fd.GetName()] = er
+	return er
+}
+
+func commentsOf(element desc.Descriptor) *Comments {
+	info := element.GetSourceInfo()
+	if fd, ok := element.(*desc.FileDescriptor); ok {
+		// a file is documented by the comments of its package statement
+		info = nil
+		for _, loc := range fd.AsFileDescriptorProto().GetSourceCodeInfo().GetLocation() {
+			if len(loc.GetPath()) == 1 && loc.GetPath()[0] == 2 {
+				info = loc
+			}
+		}
+	}
+	if info == nil {
+		return nil
+	}
+	c := &Comments{Leading: strings.TrimSpace(info.GetLeadingComments()), Trailing: strings.TrimSpace(info.GetTrailingComments())}
+	if c.Leading == "" && c.Trailing == "" {
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,57 @@
+package proto
+
+import "testing"
+
+func TestParseDocs(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"docs.proto": `syntax = "proto3";
+// Documented package
+package docs;
+import "google/protobuf/descriptor.proto";
+extend google.protobuf.MethodOptions { string owner = 50001; }
+enum State { STATE_UNSPECIFIED = 0; OPEN = 1 [deprecated = true]; }
+message Req {
+  // the node id
+  string id = 1; // trailing
+  Req next = 2;
+  map<string, State> states = 3;
+}
+// Docs service
+service Docs {
+  // Get a node
+  rpc Get (Req) returns (Req) { option idempotency_level = NO_SIDE_EFFECTS; option deprecated = true; option (owner) = "team-a"; }
+}`,
+	})
+	files, err := Parse([]string{dir + "/docs.proto"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := files[0]
+	if file.Comments == nil || file.Comments.Leading != "Documented package" {
+		t.Fatalf("file comments: %+v", file.Comments)
+	}
+	m := file.Methods[0]
+	if m.Comments.Leading != "Get a node" || m.ServiceComments.Leading != "Docs service" {
+		t.Fatalf("method comments: %+v %+v", m.Comments, m.ServiceComments)
+	}
+	if !m.Deprecated || m.IdempotencyLevel != "NO_SIDE_EFFECTS" || m.Options["[docs.owner]"] != "team-a" {
+		t.Fatalf("method options: %v %s %+v", m.Deprecated, m.IdempotencyLevel, m.Options)
+	}
+
+	// input and output are the same message, described once in the table of the file
+	input := file.Messages[m.Input]
+	if m.Input != "docs.Req" || m.Output != "docs.Req" || input == nil || len(file.Messages) != 1 {
+		t.Fatalf("message table: %s %s %+v", m.Input, m.Output, file.Messages)
+	}
+	id := input.Fields[0]
+	if id.Comments.Leading != "the node id" || id.Comments.Trailing != "trailing" || id.JsonName != "id" {
+		t.Fatalf("field docs: %+v", id)
+	}
+	if next := input.Fields[1]; next.Ref != "docs.Req" {
+		t.Fatalf("recursive field should refer to its message: %+v", next)
+	}
+	if states := input.Fields[2]; states.Label != "map" || states.Enum == nil || !states.Enum.Values[1].Deprecated || states.Ref != "" {
+		t.Fatalf("map field docs: %+v", states)
+	}
+}
@@ -0,0 +1,55 @@
+package proto
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+// HttpRule is the google.api.http binding of a method used by grpc-gateway
+type HttpRule struct {
+	Method             string      `json:"method"`
+	Path               string      `json:"path"`
+	Body               string      `json:"body,omitempty"`
+	ResponseBody       string      `json:"responseBody,omitempty"`
+	AdditionalBindings []*HttpRule `json:"additionalBindings,omitempty"`
+}
+
+// HttpRuleOf returns the http binding of method, nil when it has no google.api.http option
+func HttpRuleOf(method *desc.MethodDescriptor) *HttpRule {
+	opts := method.GetMethodOptions()
+	if opts == nil || !proto.HasExtension(opts, annotations.E_Http) {
+		return nil
+	}
+	ext, err := proto.GetExtension(opts, annotations.E_Http)
+	if err != nil {
+		return nil
+	}
+	rule, ok := ext.(*annotations.HttpRule)
+	if !ok {
+		return nil
+	}
+	return convertHttpRule(rule)
+}
+
+func convertHttpRule(rule *annotations.HttpRule) *HttpRule {
+	r := &HttpRule{Body: rule.GetBody(), ResponseBody: rule.GetResponseBody()}
+	switch pattern := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		r.Method, r.Path = "GET", pattern.Get
+	case *annotations.HttpRule_Put:
+		r.Method, r.Path = "PUT", pattern.Put
+	case *annotations.HttpRule_Post:
+		r.Method, r.Path = "POST", pattern.Post
+	case *annotations.HttpRule_Delete:
+		r.Method, r.Path = "DELETE", pattern.Delete
+	case *annotations.HttpRule_Patch:
+		r.Method, r.Path = "PATCH", pattern.Patch
+	case *annotations.HttpRule_Custom:
+		r.Method, r.Path = pattern.Custom.GetKind(), pattern.Custom.GetPath()
+	}
+	for _, binding := range rule.GetAdditionalBindings() {
+		r.AdditionalBindings = append(r.AdditionalBindings, convertHttpRule(binding))
+	}
+	return r
+}
@@ -0,0 +1,31 @@
+package proto
+
+import (
+	"testing"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+)
+
+func TestHttpRuleOf(t *testing.T) {
+	protoPath := "../test/library.proto"
+	parser := protoparse.Parser{Accessor: Accessor(protoPath, nil)}
+	fds, err := parser.ParseFiles(protoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := fds[0].FindService("library.Library")
+
+	rule := HttpRuleOf(service.FindMethodByName("CreateBook"))
+	if rule == nil || rule.Method != "POST" || rule.Path != "/v1/shelves/{shelf_id}/books" || rule.Body != "book" {
+		t.Fatalf("unexpected rule %+v", rule)
+	}
+	if len(rule.AdditionalBindings) != 1 || rule.AdditionalBindings[0].Method != "PUT" || rule.AdditionalBindings[0].Body != "*" {
+		t.Fatalf("unexpected additional bindings %+v", rule.AdditionalBindings)
+	}
+	if rule := HttpRuleOf(service.FindMethodByName("WatchBooks")); rule == nil || rule.Method != "WATCH" {
+		t.Fatalf("unexpected custom rule %+v", rule)
+	}
+	if rule := HttpRuleOf(service.FindMethodByName("DeleteBook")); rule != nil {
+		t.Fatalf("expected no rule, got %+v", rule)
+	}
+}
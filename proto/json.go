@@ -0,0 +1,110 @@
+package proto
+
+import (
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	gproto "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// UnmarshalJSON merges a body in the canonical proto3 json mapping into msg. Unlike the
+// decoder of dynamic it reads field masks as path strings, and Any type urls resolve to
+// the messages of every loaded file. Unknown fields are skipped.
+func UnmarshalJSON(msg *dynamic.Message, b []byte) error {
+	md, types, err := jsonTypes(msg.GetMessageDescriptor())
+	if err != nil {
+		return err
+	}
+	m := dynamicpb.NewMessage(md)
+	opts := protojson.UnmarshalOptions{DiscardUnknown: true, Resolver: anyStubs{types}}
+	if err := opts.Unmarshal(b, m); err != nil {
+		return err
+	}
+	wire, err := gproto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return msg.UnmarshalMerge(wire)
+}
+
+// MarshalJSON writes msg in the canonical proto3 json mapping
+func MarshalJSON(msg *dynamic.Message) ([]byte, error) {
+	md, types, err := jsonTypes(msg.GetMessageDescriptor())
+	if err != nil {
+		return nil, err
+	}
+	wire, err := msg.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	m := dynamicpb.NewMessage(md)
+	if err := gproto.Unmarshal(wire, m); err != nil {
+		return nil, err
+	}
+	return protojson.MarshalOptions{Resolver: types}.Marshal(m)
+}
+
+// jsonTypes converts md for the protobuf runtime, together with the message types of the
+// loaded files. Files that do not fit together with the file of md are left out.
+func jsonTypes(md *desc.MessageDescriptor) (protoreflect.MessageDescriptor, *protoregistry.Types, error) {
+	files, err := protodesc.NewFiles(desc.ToFileDescriptorSet(append([]*desc.FileDescriptor{md.GetFile()}, loadedFiles()...)...))
+	if err != nil {
+		if files, err = protodesc.NewFiles(desc.ToFileDescriptorSet(md.GetFile())); err != nil {
+			return nil, nil, errors.Wrapf(err, "convert descriptor of %s", md.GetFullyQualifiedName())
+		}
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(md.GetFullyQualifiedName()))
+	if err != nil {
+		return nil, nil, err
+	}
+	types := &protoregistry.Types{}
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		registerTypes(types, fd.Messages())
+		return true
+	})
+	return d.(protoreflect.MessageDescriptor), types, nil
+}
+
+func registerTypes(types *protoregistry.Types, messages protoreflect.MessageDescriptors) {
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		if !md.IsMapEntry() {
+			_ = types.RegisterMessage(dynamicpb.NewMessageType(md))
+		}
+		registerTypes(types, md.Messages())
+	}
+}
+
+// anyStubs resolves the type url without a message name of template stubs to an empty
+// message, so the Any keeps the url as written and no value
+type anyStubs struct {
+	*protoregistry.Types
+}
+
+// stubType is an empty message without a json form of its own, unlike google.protobuf.Empty
+var stubType = func() protoreflect.MessageType {
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        gproto.String("uprpc/stub.proto"),
+		Package:     gproto.String("uprpc"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: gproto.String("Stub")}},
+	}, nil)
+	if err != nil {
+		panic(err)
+	}
+	return dynamicpb.NewMessageType(fd.Messages().Get(0))
+}()
+
+func (r anyStubs) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	if strings.HasSuffix(url, "/") {
+		return stubType, nil
+	}
+	return r.Types.FindMessageByURL(url)
+}
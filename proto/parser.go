@@ -1,10 +1,8 @@
 package proto
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
+	"embed"
 	"io"
 	"os"
 	"path"
@@ -14,32 +12,48 @@ import (
 
 	osruntime "runtime"
 
-	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
 	"github.com/jhump/protoreflect/desc"
 	"github.com/jhump/protoreflect/desc/protoparse"
 	uuid "github.com/satori/go.uuid"
+	"github.com/sirupsen/logrus"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 var b2i = map[bool]int8{false: 0, true: 1}
 
+//go:embed include
+var includeFS embed.FS
+
 type File struct {
-	Id      string    `json:"id"`
-	Host    string    `json:"host"`
-	Name    string    `json:"name"`
-	Path    string    `json:"path"`
-	Methods []*Method `json:"methods"`
+	Id       string                 `json:"id"`
+	Host     string                 `json:"host"`
+	Name     string                 `json:"name"`
+	Path     string                 `json:"path"`
+	Comments *Comments              `json:"comments,omitempty"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+	Methods  []*Method              `json:"methods"`
+	// Messages describes the inputs and outputs of the methods and the messages they hold
+	Messages map[string]*Message `json:"messages,omitempty"`
 }
 
 type Method struct {
-	Id               string     `json:"id,omitempty"`
-	ServiceName      string     `json:"serviceName,omitempty"`
-	ServiceFullyName string     `json:"serviceFullyName,omitempty"`
-	Name             string     `json:"name,omitempty"`
-	Mode             int8       `json:"mode"`
-	RequestBody      string     `json:"requestBody,omitempty"`
-	RequestMds       []Metadata `json:"requestMds,omitempty"`
-	ResponseMds      []Metadata `json:"responseMds,omitempty"`
+	Id               string                 `json:"id,omitempty"`
+	ServiceName      string                 `json:"serviceName,omitempty"`
+	ServiceFullyName string                 `json:"serviceFullyName,omitempty"`
+	Name             string                 `json:"name,omitempty"`
+	Mode             int8                   `json:"mode"`
+	RequestBody      string                 `json:"requestBody,omitempty"`
+	Http             *HttpRule              `json:"http,omitempty"`
+	Comments         *Comments              `json:"comments,omitempty"`
+	ServiceComments  *Comments              `json:"serviceComments,omitempty"`
+	Deprecated       bool                   `json:"deprecated,omitempty"`
+	IdempotencyLevel string                 `json:"idempotencyLevel,omitempty"`
+	Options          map[string]interface{} `json:"options,omitempty"`
+	ServiceOptions   map[string]interface{} `json:"serviceOptions,omitempty"`
+	Input            string                 `json:"input,omitempty"`
+	Output           string                 `json:"output,omitempty"`
+	RequestMds       []Metadata             `json:"requestMds,omitempty"`
+	ResponseMds      []Metadata             `json:"responseMds,omitempty"`
 }
 
 type Metadata struct {
@@ -64,18 +78,39 @@ func ImportFile(ctx context.Context) []string {
 				DisplayName: "proto (*.proto)",
 				Pattern:     "*.proto",
 			},
+			{
+				DisplayName: "descriptor set, buf image (*.protoset, *.pb, *.binpb)",
+				Pattern:     "*.protoset;*.pb;*.binpb;*.bin;*.desc;*.protoset.json",
+			},
 		},
 	})
 	return selection
 }
 
+// Parse returns the services declared by each file, services of imported files are
+// listed under the first file importing them unless a requested file declares them
 func Parse(fileNames, includeDirs []string) ([]*File, error) {
-	var files []*File
+	var parsed []*File
+	var fileDescs [][]*desc.FileDescriptor
+	declared := map[string]bool{}
 	for _, fileName := range fileNames {
-		file, err := parseFile(fileName, includeDirs)
+		file, descs, err := parseFile(fileName, includeDirs)
 		if err != nil {
 			return nil, err
 		}
+		parsed = append(parsed, file)
+		fileDescs = append(fileDescs, descs)
+		for _, fd := range descs {
+			for _, service := range fd.GetServices() {
+				declared[service.GetFullyQualifiedName()] = true
+			}
+		}
+	}
+
+	var files []*File
+	for i, file := range parsed {
+		addImportedServices(file, fileDescs[i], declared)
+		registerMethods(file.Path, file.Methods)
 		if len(file.Methods) > 0 {
 			files = append(files, file)
 		}
@@ -83,37 +118,92 @@ func Parse(fileNames, includeDirs []string) ([]*File, error) {
 	return files, nil
 }
 
-func parseFile(protoPath string, includeDirs []string) (*File, error) {
-	fmt.Printf("parse proto file name: %+v, include dirs: %+v\n", protoPath, includeDirs)
+// addImportedServices appends the methods of services imported by fileDescs that are not declared yet
+func addImportedServices(file *File, fileDescs []*desc.FileDescriptor, declared map[string]bool) {
+	for _, fd := range importGraph(fileDescs)[len(fileDescs):] {
+		var services []*desc.ServiceDescriptor
+		for _, service := range fd.GetServices() {
+			if !declared[service.GetFullyQualifiedName()] {
+				declared[service.GetFullyQualifiedName()] = true
+				services = append(services, service)
+			}
+		}
+		file.Methods = append(file.Methods, parseMethod(services, docsInto(&file.Messages))...)
+	}
+}
+
+func parseFile(protoPath string, includeDirs []string) (*File, []*desc.FileDescriptor, error) {
+	logrus.Debugf("parse proto file name: %+v, include dirs: %+v", protoPath, includeDirs)
+	if IsDescriptorSet(protoPath) {
+		return parseDescriptorSet(protoPath)
+	}
 
 	// 创建parser对象
-	parser := protoparse.Parser{}
-	parser.Accessor = func(filename string) (io.ReadCloser, error) {
-		fmt.Printf("Accessor filename: %v \n", filename)
-		lookupFile := lookupFile(filename, append(includeDirs, path.Dir(protoPath)))
-		return os.OpenFile(lookupFile, syscall.O_RDONLY, 0)
-	}
+	parser := protoparse.Parser{Accessor: Accessor(protoPath, includeDirs), IncludeSourceCodeInfo: true}
 
 	// 使用path的方式解析得到一些列文件描述对象，这里只有一个文件描述对象
 	fileDescs, err := parser.ParseFiles(protoPath)
 	if err != nil {
-		fmt.Printf("parse proto file failed, error:  %s\n", err.Error())
-		return nil, err
+		logrus.Errorf("parse proto file failed, error: %s", err.Error())
+		return nil, nil, err
 	}
-
+	dirs := sourceDirs(protoPath, includeDirs)
 	if osruntime.GOOS == "windows" {
 		protoPath = filepath.ToSlash(protoPath)
 	}
+	register(protoPath, fileDescs, dirs)
 
 	file := File{Id: uuid.NewV4().String(), Host: "127.0.0.1:9000", Name: path.Base(protoPath), Path: protoPath}
+	file.Comments = commentsOf(fileDescs[0])
+	file.Options = newDocs().options(fileDescs[0])
 	services := fileDescs[0].GetServices()
-	file.Methods = parseMethod(services)
+	file.Methods = parseMethod(services, docsInto(&file.Messages))
+
+	return &file, fileDescs, nil
+}
+
+// parseDescriptorSet returns the services of every file in a compiled descriptor set as one File
+func parseDescriptorSet(setPath string) (*File, []*desc.FileDescriptor, error) {
+	fileDescs, err := LoadDescriptorSet(setPath)
+	if err != nil {
+		logrus.Errorf("load descriptor set failed, error: %s", err.Error())
+		return nil, nil, err
+	}
+	if osruntime.GOOS == "windows" {
+		setPath = filepath.ToSlash(setPath)
+	}
+	register(setPath, fileDescs, nil)
 
-	return &file, nil
+	file := File{Id: uuid.NewV4().String(), Host: "127.0.0.1:9000", Name: path.Base(setPath), Path: setPath}
+	file.Methods = []*Method{}
+	d := docsInto(&file.Messages)
+	for _, fd := range fileDescs {
+		file.Methods = append(file.Methods, parseMethod(fd.GetServices(), d)...)
+	}
+	return &file, fileDescs, nil
+}
+
+// Accessor opens the imports of protoPath from includeDirs and the directory of protoPath.
+// Imports found nowhere fall back to the bundled google/api protos.
+func Accessor(protoPath string, includeDirs []string) protoparse.FileAccessor {
+	return accessor(append(append([]string{}, includeDirs...), path.Dir(protoPath)))
+}
+
+func accessor(dirs []string) protoparse.FileAccessor {
+	return func(filename string) (io.ReadCloser, error) {
+		logrus.Debugf("Accessor filename: %v", filename)
+		f, err := os.OpenFile(lookupFile(filename, dirs), syscall.O_RDONLY, 0)
+		if os.IsNotExist(err) {
+			if bundled, bundledErr := includeFS.Open("include/" + filename); bundledErr == nil {
+				return bundled, nil
+			}
+		}
+		return f, err
+	}
 }
 
 func lookupFile(fileName string, includeDirs []string) string {
-	fmt.Printf("lookupFile includeDirs: %v\n", includeDirs)
+	logrus.Debugf("lookupFile includeDirs: %v", includeDirs)
 	for _, dir := range includeDirs {
 		joinFile := path.Join(dir, fileName)
 		if ok, _ := file.ExistPath(joinFile); ok {
@@ -123,14 +213,13 @@ func lookupFile(fileName string, includeDirs []string) string {
 	return fileName
 }
 
-func parseMethod(services []*desc.ServiceDescriptor) []*Method {
+func parseMethod(services []*desc.ServiceDescriptor, d *docs) []*Method {
 	var methods = []*Method{}
 	for _, service := range services {
 		for _, method := range service.GetMethods() {
-			fieldValue := parseMessageFields(method.GetInputType())
-			str, err := json.MarshalIndent(&fieldValue, "", "\t")
+			body, err := renderTemplate(method.GetInputType(), TemplateSample)
 			if err != nil {
-				fmt.Println(err)
+				logrus.Error(err)
 			}
 
 			m := &Method{
@@ -139,88 +228,12 @@ func parseMethod(services []*desc.ServiceDescriptor) []*Method {
 				ServiceFullyName: service.GetFullyQualifiedName(),
 				Name:             method.GetName(),
 				Mode:             b2i[method.IsServerStreaming()]<<1 | b2i[method.IsClientStreaming()],
-				RequestBody:      string(str),
+				RequestBody:      body,
+				Http:             HttpRuleOf(method),
 			}
+			d.describeMethod(m, method)
 			methods = append(methods, m)
 		}
 	}
 	return methods
 }
-
-func parseMessageFields(messageDesc *desc.MessageDescriptor) interface{} {
-	fieldsData := map[string]interface{}{}
-	for _, field := range messageDesc.GetFields() {
-		if field.IsRepeated() && !field.IsMap() {
-			fieldsData[field.GetName()] = []interface{}{parseField(field)}
-		} else {
-			fieldsData[field.GetName()] = parseField(field)
-		}
-	}
-	return fieldsData
-}
-
-func parseField(field *desc.FieldDescriptor) interface{} {
-	if field.IsMap() {
-		var v interface{}
-		if field.GetMapValueType().GetType() == dpb.FieldDescriptorProto_TYPE_MESSAGE {
-			v = parseMessageFields(field.GetMapValueType().GetMessageType())
-		} else {
-			v = parseField(field.GetMapValueType())
-		}
-		if field.GetMapKeyType().GetType() == dpb.FieldDescriptorProto_TYPE_STRING {
-			return map[string]interface{}{"key": v}
-		} else {
-			return map[int]interface{}{32: v}
-		}
-	}
-
-	if field.GetOneOf() != nil && getValue(field.GetType()) == nil {
-		return parseField(field.GetOneOf().GetChoices()[0])
-	}
-
-	switch field.GetType() {
-	case dpb.FieldDescriptorProto_TYPE_MESSAGE:
-		return parseMessageFields(field.GetMessageType())
-	case dpb.FieldDescriptorProto_TYPE_ENUM:
-		return field.GetEnumType().GetValues()[0].GetNumber()
-	default:
-		return getValue(field.GetType())
-	}
-}
-
-func getValue(fieldType dpb.FieldDescriptorProto_Type) interface{} {
-	switch fieldType {
-	case dpb.FieldDescriptorProto_TYPE_DOUBLE:
-		return 3.141592
-	case dpb.FieldDescriptorProto_TYPE_FLOAT:
-		return 5.512322
-	case dpb.FieldDescriptorProto_TYPE_INT64:
-		return 6400
-	case dpb.FieldDescriptorProto_TYPE_UINT64:
-		return 64000
-	case dpb.FieldDescriptorProto_TYPE_INT32:
-		return 3200
-	case dpb.FieldDescriptorProto_TYPE_FIXED32:
-		return 3200
-	case dpb.FieldDescriptorProto_TYPE_FIXED64:
-		return 6400
-	case dpb.FieldDescriptorProto_TYPE_BOOL:
-		return true
-	case dpb.FieldDescriptorProto_TYPE_STRING:
-		return ""
-	case dpb.FieldDescriptorProto_TYPE_UINT32:
-		return 32000
-	case dpb.FieldDescriptorProto_TYPE_BYTES:
-		return bytes.Buffer{}
-	case dpb.FieldDescriptorProto_TYPE_SFIXED32:
-		return 320
-	case dpb.FieldDescriptorProto_TYPE_SFIXED64:
-		return 640
-	case dpb.FieldDescriptorProto_TYPE_SINT32:
-		return 320
-	case dpb.FieldDescriptorProto_TYPE_SINT64:
-		return 640
-	default:
-		return nil
-	}
-}
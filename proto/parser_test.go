@@ -3,7 +3,10 @@ package proto
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
+
+	"github.com/jhump/protoreflect/dynamic"
 )
 
 func TestParse(t *testing.T) {
@@ -21,3 +24,130 @@ func TestLookupFile(t *testing.T) {
 	path := lookupFile(filename, includeDirs)
 	fmt.Println(path)
 }
+
+func TestParseReplacesMethodIds(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"svc.proto": `syntax = "proto3"; package ids; message M {} service S { rpc A (M) returns (M); rpc B (M) returns (M); }`,
+	})
+	protoPath := dir + "/svc.proto"
+	first, err := Parse([]string{protoPath}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Parse([]string{protoPath}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range first[0].Methods {
+		if FindMethodById(m.Id) != nil {
+			t.Fatalf("id of %s outlives the parse it came from", m.Name)
+		}
+	}
+	registry.RLock()
+	owned := len(registry.owners[protoPath])
+	registry.RUnlock()
+	if owned != 2 || FindMethodById(second[0].Methods[1].Id) == nil {
+		t.Fatalf("want the 2 ids of the last parse, got %d", owned)
+	}
+
+	unregister(protoPath)
+	if FindMethodById(second[0].Methods[0].Id) != nil {
+		t.Fatal("ids of a removed file are still registered")
+	}
+}
+
+func TestParseImportedServices(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.proto":  `syntax = "proto3"; package graph; import "other.proto"; service Main { rpc A (M) returns (M); }`,
+		"other.proto": `syntax = "proto3"; package graph; message M {} service Other { rpc B (M) returns (M); }`,
+	})
+	mainPath, otherPath := dir+"/main.proto", dir+"/other.proto"
+
+	files, err := Parse([]string{mainPath}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || len(files[0].Methods) != 2 || files[0].Methods[1].ServiceFullyName != "graph.Other" {
+		t.Fatalf("imported services are missing: %+v", files)
+	}
+	if FindMethod("graph.Other", "B") == nil {
+		t.Fatal("imported service is not registered")
+	}
+
+	files, err = Parse([]string{mainPath, otherPath}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || len(files[0].Methods) != 1 || len(files[1].Methods) != 1 {
+		t.Fatalf("services should be listed under their declaring file: %+v", files)
+	}
+}
+
+func TestParseRecursiveMessages(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"tree.proto": `syntax = "proto3"; package tree; import "google/protobuf/struct.proto";
+message Node { string name = 1; Node parent = 2; repeated Node children = 3; map<string, Node> named = 4; google.protobuf.Struct meta = 5; }
+service Tree { rpc Get (Node) returns (Node); }`,
+	})
+
+	files, err := Parse([]string{dir + "/tree.proto"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(files[0].Methods[0].RequestBody), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["parent"] != nil || len(body["children"].([]interface{})) != 0 || len(body["named"].(map[string]interface{})) != 0 {
+		t.Fatalf("recursive fields should be cut off: %+v", body)
+	}
+}
+
+func TestParseWellKnownTypes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"wkt.proto": `syntax = "proto3"; package wkt;
+import "google/protobuf/timestamp.proto"; import "google/protobuf/duration.proto"; import "google/protobuf/wrappers.proto";
+import "google/protobuf/struct.proto"; import "google/protobuf/field_mask.proto"; import "google/protobuf/any.proto";
+message Req { google.protobuf.Timestamp at = 1; google.protobuf.Duration ttl = 2; google.protobuf.Int32Value limit = 3;
+  google.protobuf.Struct meta = 4; google.protobuf.FieldMask mask = 5; google.protobuf.Any detail = 6; }
+service Wkt { rpc Get (Req) returns (Req); }`,
+	})
+
+	files, err := Parse([]string{dir + "/wkt.proto"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(files[0].Methods[0].RequestBody), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["at"] != "2024-01-01T08:00:00Z" || body["ttl"] != "1.5s" || body["limit"] != 42.0 || body["mask"] != "" {
+		t.Fatalf("well-known types should use their json forms: %+v", body)
+	}
+	if body["detail"].(map[string]interface{})["@type"] == nil || len(body["meta"].(map[string]interface{})) != 0 {
+		t.Fatalf("well-known types should use their json forms: %+v", body)
+	}
+
+	// the canonical forms go through the request decoder
+	input := FindMethod("wkt.Wkt", "Get").GetInputType()
+	if err := UnmarshalJSON(dynamic.NewMessage(input), []byte(files[0].Methods[0].RequestBody)); err != nil {
+		t.Fatalf("template does not decode: %v", err)
+	}
+	msg := dynamic.NewMessage(input)
+	err = UnmarshalJSON(msg, []byte(`{"mask":"at,meta.userName","detail":{"@type":"type.googleapis.com/wkt.Req","ttl":"2s"},"unknown":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := MarshalJSON(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"mask":"at,meta.userName","detail":{"@type":"type.googleapis.com/wkt.Req","ttl":"2s"}}`
+	if compact := strings.ReplaceAll(string(b), " ", ""); compact != want {
+		t.Fatalf("want %s, got %s", want, compact)
+	}
+}
@@ -0,0 +1,120 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/pkg/errors"
+)
+
+// Reconciled is a saved request body fitted to the current input message of its method
+type Reconciled struct {
+	Body string `json:"body"`
+	// Removed lists the dotted paths of fields the message does not have anymore
+	Removed []string `json:"removed,omitempty"`
+}
+
+// ReconcileBody drops the fields of a saved request body that were removed from the input
+// message. Bodies without removed fields are returned unchanged.
+func ReconcileBody(serviceFullyName, methodName, body string) (*Reconciled, error) {
+	method := FindMethod(serviceFullyName, methodName)
+	if method == nil {
+		return nil, errors.Errorf("method %s/%s not found", serviceFullyName, methodName)
+	}
+	if strings.TrimSpace(body) == "" {
+		return &Reconciled{Body: body}, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(StripComments(body)))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, errors.Wrap(err, "request body is not valid json")
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("request body must be a json object")
+	}
+
+	var removed []string
+	reconcileMessage(method.GetInputType(), fields, "", &removed)
+	removed = unique(removed)
+	if len(removed) == 0 {
+		return &Reconciled{Body: body}, nil
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "\t")
+	if err := encoder.Encode(fields); err != nil {
+		return nil, err
+	}
+	return &Reconciled{Body: strings.TrimSuffix(buf.String(), "\n"), Removed: removed}, nil
+}
+
+func reconcileMessage(md *desc.MessageDescriptor, fields map[string]interface{}, prefix string, removed *[]string) {
+	// well-known types have their own json forms
+	if strings.HasPrefix(md.GetFullyQualifiedName(), "google.protobuf.") {
+		return
+	}
+	for key, value := range fields {
+		field := md.FindFieldByName(key)
+		if field == nil {
+			field = md.FindFieldByJSONName(key)
+		}
+		if field == nil {
+			*removed = append(*removed, prefix+key)
+			delete(fields, key)
+			continue
+		}
+
+		var nested *desc.MessageDescriptor
+		if field.IsMap() {
+			nested = field.GetMapValueType().GetMessageType()
+		} else {
+			nested = field.GetMessageType()
+		}
+		if nested == nil {
+			continue
+		}
+		path := prefix + key + "."
+		switch {
+		case field.IsMap():
+			if entries, ok := value.(map[string]interface{}); ok {
+				for k, entry := range entries {
+					if m, ok := entry.(map[string]interface{}); ok {
+						reconcileMessage(nested, m, path+k+".", removed)
+					}
+				}
+			}
+		case field.IsRepeated():
+			if items, ok := value.([]interface{}); ok {
+				for _, item := range items {
+					if m, ok := item.(map[string]interface{}); ok {
+						reconcileMessage(nested, m, path, removed)
+					}
+				}
+			}
+		default:
+			if m, ok := value.(map[string]interface{}); ok {
+				reconcileMessage(nested, m, path, removed)
+			}
+		}
+	}
+}
+
+func unique(list []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
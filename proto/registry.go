@@ -0,0 +1,220 @@
+package proto
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jhump/protoreflect/desc"
+)
+
+// registry holds the services of every parsed file by fully-qualified name, so methods
+// can be invoked without the source they were loaded from
+var registry = struct {
+	sync.RWMutex
+	services map[string]*desc.ServiceDescriptor
+	// methods maps the ids of the Method models to service/method keys
+	methods map[string]string
+	// owners maps the path a model was loaded from to the ids of its methods
+	owners map[string][]string
+	// files holds the loaded files and imports by their path on disk, or by their name
+	// when they have none, e.g. the files of descriptor sets and bundled imports
+	files map[string]*desc.FileDescriptor
+	// loaded maps the path a model was loaded from to the keys of the files it needs
+	loaded map[string][]string
+}{
+	services: map[string]*desc.ServiceDescriptor{},
+	methods:  map[string]string{},
+	owners:   map[string][]string{},
+	files:    map[string]*desc.FileDescriptor{},
+	loaded:   map[string][]string{},
+}
+
+// register replaces the files of owner with files and everything they import. dirs are
+// the dirs the names of the files were resolved against.
+func register(owner string, files []*desc.FileDescriptor, dirs []string) {
+	registry.Lock()
+	defer registry.Unlock()
+	keys := []string{}
+	for _, fd := range importGraph(files) {
+		key := sourcePath(fd.GetName(), dirs)
+		registry.files[key] = fd
+		keys = append(keys, key)
+	}
+	registry.loaded[owner] = keys
+	pruneFiles()
+}
+
+// sourcePath is the absolute path of the file name resolves to, name itself when no file does
+func sourcePath(name string, dirs []string) string {
+	p := name
+	if !filepath.IsAbs(name) {
+		if p = lookupFile(name, dirs); p == name {
+			return name
+		}
+	}
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	return filepath.ToSlash(p)
+}
+
+// loadedPaths lists the files on disk loaded by owner
+func loadedPaths(owner string) []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	var paths []string
+	for _, key := range registry.loaded[owner] {
+		if filepath.IsAbs(key) {
+			paths = append(paths, key)
+		}
+	}
+	return paths
+}
+
+// pruneFiles drops the files no owner needs anymore and indexes the services of the rest
+func pruneFiles() {
+	needed := map[string]bool{}
+	for _, keys := range registry.loaded {
+		for _, key := range keys {
+			needed[key] = true
+		}
+	}
+	registry.services = map[string]*desc.ServiceDescriptor{}
+	for key, fd := range registry.files {
+		if !needed[key] {
+			delete(registry.files, key)
+			continue
+		}
+		for _, service := range fd.GetServices() {
+			registry.services[service.GetFullyQualifiedName()] = service
+		}
+	}
+}
+
+// registerMethods replaces the method ids of owner with the ids of methods
+func registerMethods(owner string, methods []*Method) {
+	registry.Lock()
+	defer registry.Unlock()
+	dropMethods(owner)
+	ids := make([]string, 0, len(methods))
+	for _, m := range methods {
+		registry.methods[m.Id] = m.ServiceFullyName + "/" + m.Name
+		ids = append(ids, m.Id)
+	}
+	registry.owners[owner] = ids
+}
+
+// unregister forgets the method ids of owner and the files only it needed
+func unregister(owner string) {
+	registry.Lock()
+	defer registry.Unlock()
+	dropMethods(owner)
+	delete(registry.loaded, owner)
+	pruneFiles()
+}
+
+func dropMethods(owner string) {
+	for _, id := range registry.owners[owner] {
+		delete(registry.methods, id)
+	}
+	delete(registry.owners, owner)
+}
+
+// importGraph returns files followed by their transitive imports, each file once
+func importGraph(files []*desc.FileDescriptor) []*desc.FileDescriptor {
+	seen := map[string]bool{}
+	var graph []*desc.FileDescriptor
+	var visit func(fd *desc.FileDescriptor)
+	visit = func(fd *desc.FileDescriptor) {
+		if seen[fd.GetName()] {
+			return
+		}
+		seen[fd.GetName()] = true
+		graph = append(graph, fd)
+		for _, dep := range fd.GetDependencies() {
+			visit(dep)
+		}
+	}
+	for _, fd := range files {
+		visit(fd)
+	}
+	return graph
+}
+
+// FindService returns a registered service, nil when no parsed file declares it
+func FindService(serviceFullyName string) *desc.ServiceDescriptor {
+	registry.RLock()
+	defer registry.RUnlock()
+	return registry.services[serviceFullyName]
+}
+
+// Services lists the fully-qualified names of the registered services in order
+func Services() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.services))
+	for name := range registry.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FindMethod returns a method of a registered service
+func FindMethod(serviceFullyName, methodName string) *desc.MethodDescriptor {
+	service := FindService(serviceFullyName)
+	if service == nil {
+		return nil
+	}
+	return service.FindMethodByName(methodName)
+}
+
+// loadedFiles returns every registered file and import sorted by name
+func loadedFiles() []*desc.FileDescriptor {
+	registry.RLock()
+	defer registry.RUnlock()
+	files := make([]*desc.FileDescriptor, 0, len(registry.files))
+	for _, fd := range registry.files {
+		files = append(files, fd)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].GetName() < files[j].GetName() })
+	return files
+}
+
+// FindMessage returns a message type declared in a registered file or import
+func FindMessage(fullName string) *desc.MessageDescriptor {
+	for _, fd := range loadedFiles() {
+		if md := fd.FindMessage(fullName); md != nil {
+			return md
+		}
+	}
+	return nil
+}
+
+// FindMethodById returns the method of a Method model by its id
+func FindMethodById(id string) *desc.MethodDescriptor {
+	registry.RLock()
+	key, ok := registry.methods[id]
+	registry.RUnlock()
+	if !ok {
+		return nil
+	}
+	i := strings.LastIndex(key, "/")
+	return FindMethod(key[:i], key[i+1:])
+}
+
+// Load parses a proto source or descriptor set and registers its services
+func Load(protoPath string, includeDirs []string) error {
+	if IsDescriptorSet(protoPath) {
+		fileDescs, err := LoadDescriptorSet(protoPath)
+		if err != nil {
+			return err
+		}
+		register(protoPath, fileDescs, nil)
+		return nil
+	}
+	_, err := parseDescs(protoPath, []string{protoPath}, sourceDirs(protoPath, includeDirs))
+	return err
+}
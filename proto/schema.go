@@ -0,0 +1,207 @@
+package proto
+
+import (
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/pkg/errors"
+)
+
+// InputSchema derives a JSON Schema of the request body of a method for completion and
+// validation in the body editor. Messages are definitions, so recursive types are fine.
+func InputSchema(methodId string) (map[string]interface{}, error) {
+	method := FindMethodById(methodId)
+	if method == nil {
+		return nil, errors.Errorf("method %s not found", methodId)
+	}
+	s := &jsonSchema{definitions: map[string]interface{}{}}
+	input := method.GetInputType()
+	root := s.message(input)
+	if _, ok := root["$ref"]; ok {
+		// keywords beside $ref are ignored by draft-07, so the root is the definition itself
+		root = map[string]interface{}{}
+		for k, v := range s.definitions[input.GetFullyQualifiedName()].(map[string]interface{}) {
+			root[k] = v
+		}
+	}
+	root["$schema"] = "http://json-schema.org/draft-07/schema#"
+	root["definitions"] = s.definitions
+	return root, nil
+}
+
+type jsonSchema struct {
+	definitions map[string]interface{}
+}
+
+// message returns a reference to the definition of md, or the json form of a well-known type
+func (s *jsonSchema) message(md *desc.MessageDescriptor) map[string]interface{} {
+	if schema, ok := wellKnownSchema(md); ok {
+		return schema
+	}
+	name := md.GetFullyQualifiedName()
+	ref := map[string]interface{}{"$ref": "#/definitions/" + name}
+	if _, ok := s.definitions[name]; ok {
+		return ref
+	}
+	// placeholder first so recursive fields stop at the reference
+	s.definitions[name] = nil
+
+	// protojson accepts the proto names of fields as well as their json names
+	properties := map[string]interface{}{}
+	var required []string
+	var oneOfs []interface{}
+	for _, field := range md.GetFields() {
+		schema := s.field(field)
+		properties[field.GetJSONName()] = schema
+		properties[field.GetName()] = schema
+		if !isRequired(field) {
+			continue
+		}
+		if field.GetName() == field.GetJSONName() {
+			required = append(required, field.GetJSONName())
+		} else {
+			oneOfs = append(oneOfs, present(field))
+		}
+	}
+	for _, oneOf := range md.GetOneOfs() {
+		if oneOf.IsSynthetic() {
+			continue
+		}
+		// at most one choice is set
+		var choices []interface{}
+		for _, choice := range oneOf.GetChoices() {
+			choices = append(choices, present(choice))
+		}
+		oneOfs = append(oneOfs, map[string]interface{}{
+			"oneOf": append(choices, map[string]interface{}{"not": map[string]interface{}{"anyOf": choices}}),
+		})
+	}
+
+	definition := map[string]interface{}{
+		"type":                 "object",
+		"title":                md.GetName(),
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	describe(definition, md, md.GetMessageOptions().GetDeprecated())
+	if len(required) > 0 {
+		definition["required"] = required
+	}
+	if len(oneOfs) > 0 {
+		definition["allOf"] = oneOfs
+	}
+	s.definitions[name] = definition
+	return ref
+}
+
+// present is satisfied by an object setting field under either of its names
+func present(field *desc.FieldDescriptor) map[string]interface{} {
+	if field.GetName() == field.GetJSONName() {
+		return map[string]interface{}{"required": []string{field.GetName()}}
+	}
+	return map[string]interface{}{"anyOf": []interface{}{
+		map[string]interface{}{"required": []string{field.GetJSONName()}},
+		map[string]interface{}{"required": []string{field.GetName()}},
+	}}
+}
+
+func (s *jsonSchema) field(field *desc.FieldDescriptor) map[string]interface{} {
+	var schema map[string]interface{}
+	switch {
+	case field.IsMap():
+		schema = map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": s.value(field.GetMapValueType()),
+		}
+		// keys of every type are strings in json
+		switch field.GetMapKeyType().GetType() {
+		case dpb.FieldDescriptorProto_TYPE_STRING:
+		case dpb.FieldDescriptorProto_TYPE_BOOL:
+			schema["propertyNames"] = map[string]interface{}{"enum": []string{"true", "false"}}
+		default:
+			schema["propertyNames"] = map[string]interface{}{"pattern": "^-?[0-9]+$"}
+		}
+	case field.IsRepeated():
+		schema = map[string]interface{}{"type": "array", "items": s.value(field)}
+	default:
+		schema = s.value(field)
+	}
+	describe(schema, field, field.GetFieldOptions().GetDeprecated())
+	return schema
+}
+
+// value is the schema of a single value of field regardless of its label
+func (s *jsonSchema) value(field *desc.FieldDescriptor) map[string]interface{} {
+	switch field.GetType() {
+	case dpb.FieldDescriptorProto_TYPE_MESSAGE, dpb.FieldDescriptorProto_TYPE_GROUP:
+		return s.message(field.GetMessageType())
+	case dpb.FieldDescriptorProto_TYPE_ENUM:
+		var names []string
+		for _, v := range field.GetEnumType().GetValues() {
+			names = append(names, v.GetName())
+		}
+		schema := map[string]interface{}{"type": "string", "enum": names, "title": field.GetEnumType().GetName()}
+		describe(schema, field.GetEnumType(), field.GetEnumType().GetEnumOptions().GetDeprecated())
+		return schema
+	case dpb.FieldDescriptorProto_TYPE_DOUBLE, dpb.FieldDescriptorProto_TYPE_FLOAT:
+		return map[string]interface{}{"type": "number"}
+	case dpb.FieldDescriptorProto_TYPE_INT32, dpb.FieldDescriptorProto_TYPE_SINT32, dpb.FieldDescriptorProto_TYPE_SFIXED32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case dpb.FieldDescriptorProto_TYPE_UINT32, dpb.FieldDescriptorProto_TYPE_FIXED32:
+		return map[string]interface{}{"type": "integer", "format": "uint32", "minimum": 0}
+	case dpb.FieldDescriptorProto_TYPE_INT64, dpb.FieldDescriptorProto_TYPE_SINT64, dpb.FieldDescriptorProto_TYPE_SFIXED64:
+		// 64-bit integers are strings in protojson, numbers are accepted too
+		return map[string]interface{}{"type": []string{"string", "integer"}, "format": "int64", "pattern": "^-?[0-9]+$"}
+	case dpb.FieldDescriptorProto_TYPE_UINT64, dpb.FieldDescriptorProto_TYPE_FIXED64:
+		return map[string]interface{}{"type": []string{"string", "integer"}, "format": "uint64", "pattern": "^[0-9]+$"}
+	case dpb.FieldDescriptorProto_TYPE_BOOL:
+		return map[string]interface{}{"type": "boolean"}
+	case dpb.FieldDescriptorProto_TYPE_BYTES:
+		return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
+func wellKnownSchema(md *desc.MessageDescriptor) (map[string]interface{}, bool) {
+	switch md.GetFullyQualifiedName() {
+	case "google.protobuf.Timestamp":
+		return map[string]interface{}{"type": "string", "format": "date-time"}, true
+	case "google.protobuf.Duration":
+		return map[string]interface{}{"type": "string", "pattern": "^-?[0-9]+(\\.[0-9]{1,9})?s$"}, true
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		return (&jsonSchema{}).value(md.FindFieldByName("value")), true
+	case "google.protobuf.Struct":
+		return map[string]interface{}{"type": "object"}, true
+	case "google.protobuf.Value":
+		return map[string]interface{}{}, true
+	case "google.protobuf.ListValue":
+		return map[string]interface{}{"type": "array"}, true
+	case "google.protobuf.FieldMask":
+		return map[string]interface{}{"type": "string", "description": "comma separated field paths"}, true
+	case "google.protobuf.Any":
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"@type": map[string]interface{}{"type": "string"}},
+			"required":   []string{"@type"},
+		}, true
+	case "google.protobuf.Empty":
+		return map[string]interface{}{"type": "object", "additionalProperties": false}, true
+	}
+	return nil, false
+}
+
+// describe sets the proto comment of d as description and flags deprecated elements
+func describe(schema map[string]interface{}, d desc.Descriptor, deprecated bool) {
+	if c := commentsOf(d); c != nil {
+		schema["description"] = c.Leading
+		if c.Leading == "" {
+			schema["description"] = c.Trailing
+		}
+	}
+	if deprecated {
+		schema["deprecated"] = true
+	}
+}
@@ -0,0 +1,69 @@
+package proto
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+func TestInputSchema(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"node.proto": `syntax = "proto3"; package node; import "google/protobuf/timestamp.proto"; import "google/protobuf/field_mask.proto";
+enum Color { COLOR_UNSPECIFIED = 0; RED = 1; }
+message Node {
+  // display name
+  string name = 1;
+  Node parent = 2;
+  map<int32, Node> children = 3;
+  repeated Color colors = 4;
+  google.protobuf.Timestamp created = 5;
+  int32 old = 6 [deprecated = true];
+  oneof ref { string path = 7; int64 number = 8; }
+  int64 total_count = 9;
+  google.protobuf.FieldMask mask = 10;
+}
+service Tree { rpc Get (Node) returns (Node); }`,
+	})
+	files, err := Parse([]string{dir + "/node.proto"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := InputSchema(files[0].Methods[0].Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := schema["$ref"]; ok || schema["title"] != "Node" || schema["properties"] == nil {
+		t.Fatalf("root should be the definition of the input: %v", schema)
+	}
+	b, _ := json.Marshal(schema)
+	for _, want := range []string{
+		`"$ref":"#/definitions/node.Node"`,
+		`"name":{"description":"display name","type":"string"}`,
+		`"parent":{"$ref":"#/definitions/node.Node"}`,
+		`"colors":{"items":{"enum":["COLOR_UNSPECIFIED","RED"],"title":"Color","type":"string"},"type":"array"}`,
+		`"created":{"format":"date-time","type":"string"}`,
+		`"old":{"deprecated":true,"format":"int32","type":"integer"}`,
+		`{"required":["path"]},{"required":["number"]}`,
+		`"totalCount":{"format":"int64"`,
+		`"total_count":{"format":"int64"`,
+		`"mask":{"description":"comma separated field paths","type":"string"}`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Fatalf("schema misses %s: %s", want, b)
+		}
+	}
+
+	// a body valid for the schema decodes
+	msg := dynamic.NewMessage(FindMethodById(files[0].Methods[0].Id).GetInputType())
+	if err := UnmarshalJSON(msg, []byte(`{"mask":"name,parent.totalCount","total_count":"3"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := InputSchema("unknown"); err == nil {
+		t.Fatal("unknown method id should fail")
+	}
+}
@@ -0,0 +1,347 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+const (
+	// TemplateZero fills every field with its zero value
+	TemplateZero = "zero"
+	// TemplateSample fills every field with a sample value, oneofs with their first choice
+	TemplateSample = "sample"
+	// TemplateAll is TemplateSample with the other oneof choices as commented lines
+	TemplateAll = "all"
+	// TemplateRequired only keeps proto2 required fields and the ones marked
+	// (google.api.field_behavior) = REQUIRED
+	TemplateRequired = "required"
+)
+
+// maxTemplateDepth caps the nesting of request body templates
+const maxTemplateDepth = 8
+
+// RequestTemplate renders a request body for the input message of a method
+func RequestTemplate(serviceFullyName, methodName, mode string) (string, error) {
+	method := FindMethod(serviceFullyName, methodName)
+	if method == nil {
+		return "", errors.Errorf("method %s/%s not found", serviceFullyName, methodName)
+	}
+	return renderTemplate(method.GetInputType(), mode)
+}
+
+// StripComments removes the commented lines of a TemplateAll body so it parses as json
+func StripComments(body string) string {
+	if !strings.Contains(body, "//") {
+		return body
+	}
+	lines := strings.Split(body, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "//") {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+func renderTemplate(messageDesc *desc.MessageDescriptor, mode string) (string, error) {
+	switch mode {
+	case TemplateZero, TemplateSample, TemplateAll, TemplateRequired:
+	default:
+		return "", errors.Errorf("unknown template mode %s", mode)
+	}
+	t := &template{mode: mode, visiting: map[string]bool{}}
+	var buf bytes.Buffer
+	writeJSON(&buf, t.message(messageDesc), "")
+	return buf.String(), nil
+}
+
+// template tracks the messages being expanded, a message nested in itself is cut off
+type template struct {
+	mode     string
+	visiting map[string]bool
+	depth    int
+}
+
+// object keeps the members of a json object in field order
+type object struct {
+	members []member
+}
+
+type member struct {
+	key   string
+	value interface{}
+	// comment members are written as commented lines
+	comment bool
+}
+
+func (o *object) add(key string, value interface{}, comment bool) {
+	o.members = append(o.members, member{key: key, value: value, comment: comment})
+}
+
+// message returns nil for a recursive or too deeply nested message
+func (t *template) message(messageDesc *desc.MessageDescriptor) interface{} {
+	name := messageDesc.GetFullyQualifiedName()
+	if v, ok := t.wellKnown(messageDesc); ok {
+		return v
+	}
+	if t.visiting[name] || t.depth >= maxTemplateDepth {
+		return nil
+	}
+	t.visiting[name] = true
+	t.depth++
+	defer func() {
+		delete(t.visiting, name)
+		t.depth--
+	}()
+
+	fields := &object{}
+	for _, field := range messageDesc.GetFields() {
+		if t.mode == TemplateRequired && !isRequired(field) {
+			continue
+		}
+		oneOf := field.GetOneOf()
+		if oneOf == nil || oneOf.IsSynthetic() {
+			fields.add(field.GetJSONName(), t.field(field), false)
+			continue
+		}
+		// a oneof is written at its first choice, the others follow as comments
+		choices := oneOf.GetChoices()
+		if choices[0] != field {
+			continue
+		}
+		fields.add(field.GetJSONName(), t.field(field), false)
+		if t.mode == TemplateAll {
+			for _, choice := range choices[1:] {
+				fields.add(choice.GetJSONName(), t.field(choice), true)
+			}
+		}
+	}
+	return fields
+}
+
+func (t *template) field(field *desc.FieldDescriptor) interface{} {
+	if field.IsMap() {
+		entries := &object{}
+		if t.mode == TemplateZero {
+			return entries
+		}
+		if v := t.value(field.GetMapValueType()); v != nil {
+			entries.add(t.mapKey(field.GetMapKeyType()), v, false)
+		}
+		return entries
+	}
+	if field.IsRepeated() {
+		if v := t.value(field); v != nil && t.mode != TemplateZero {
+			return []interface{}{v}
+		}
+		return []interface{}{}
+	}
+	return t.value(field)
+}
+
+// value is a single value of field regardless of its label
+func (t *template) value(field *desc.FieldDescriptor) interface{} {
+	switch field.GetType() {
+	case dpb.FieldDescriptorProto_TYPE_MESSAGE, dpb.FieldDescriptorProto_TYPE_GROUP:
+		return t.message(field.GetMessageType())
+	case dpb.FieldDescriptorProto_TYPE_ENUM:
+		values := field.GetEnumType().GetValues()
+		// the first value is usually UNSPECIFIED
+		if t.mode != TemplateZero && len(values) > 1 {
+			return values[1].GetName()
+		}
+		return values[0].GetName()
+	}
+	if t.mode == TemplateZero {
+		return zeroValue(field.GetType())
+	}
+	return sampleValue(field)
+}
+
+// mapKey is a json object key, map keys of every type are strings in json
+func (t *template) mapKey(field *desc.FieldDescriptor) string {
+	switch field.GetType() {
+	case dpb.FieldDescriptorProto_TYPE_STRING:
+		return "key"
+	case dpb.FieldDescriptorProto_TYPE_BOOL:
+		return "true"
+	default:
+		return "42"
+	}
+}
+
+// wellKnown returns the canonical json form of the well-known types that have one
+func (t *template) wellKnown(messageDesc *desc.MessageDescriptor) (interface{}, bool) {
+	switch messageDesc.GetFullyQualifiedName() {
+	case "google.protobuf.Timestamp":
+		if t.mode == TemplateZero {
+			return "1970-01-01T00:00:00Z", true
+		}
+		return "2024-01-01T08:00:00Z", true
+	case "google.protobuf.Duration":
+		if t.mode == TemplateZero {
+			return "0s", true
+		}
+		return "1.5s", true
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		return t.value(messageDesc.FindFieldByName("value")), true
+	case "google.protobuf.Struct":
+		return &object{}, true
+	case "google.protobuf.Value":
+		return nil, true
+	case "google.protobuf.ListValue":
+		return []interface{}{}, true
+	case "google.protobuf.FieldMask":
+		// comma separated field paths of the message it masks
+		return "", true
+	case "google.protobuf.Any":
+		stub := &object{}
+		stub.add("@type", "type.googleapis.com/", false)
+		return stub, true
+	}
+	return nil, false
+}
+
+// zeroValue follows the proto3 json mapping, 64-bit integers are strings
+func zeroValue(fieldType dpb.FieldDescriptorProto_Type) interface{} {
+	switch fieldType {
+	case dpb.FieldDescriptorProto_TYPE_BOOL:
+		return false
+	case dpb.FieldDescriptorProto_TYPE_STRING, dpb.FieldDescriptorProto_TYPE_BYTES:
+		return ""
+	case dpb.FieldDescriptorProto_TYPE_INT64, dpb.FieldDescriptorProto_TYPE_UINT64,
+		dpb.FieldDescriptorProto_TYPE_SINT64, dpb.FieldDescriptorProto_TYPE_FIXED64,
+		dpb.FieldDescriptorProto_TYPE_SFIXED64:
+		return "0"
+	default:
+		return 0
+	}
+}
+
+func sampleValue(field *desc.FieldDescriptor) interface{} {
+	switch field.GetType() {
+	case dpb.FieldDescriptorProto_TYPE_DOUBLE, dpb.FieldDescriptorProto_TYPE_FLOAT:
+		return 1.5
+	case dpb.FieldDescriptorProto_TYPE_BOOL:
+		return true
+	case dpb.FieldDescriptorProto_TYPE_STRING:
+		return sampleString(field.GetName())
+	case dpb.FieldDescriptorProto_TYPE_BYTES:
+		return base64.StdEncoding.EncodeToString([]byte(sampleString(field.GetName())))
+	case dpb.FieldDescriptorProto_TYPE_INT64, dpb.FieldDescriptorProto_TYPE_UINT64,
+		dpb.FieldDescriptorProto_TYPE_SINT64, dpb.FieldDescriptorProto_TYPE_FIXED64,
+		dpb.FieldDescriptorProto_TYPE_SFIXED64:
+		return "42"
+	default:
+		return 42
+	}
+}
+
+// sampleString guesses a plausible value from the field name
+func sampleString(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.Contains(name, "email"):
+		return "user@example.com"
+	case strings.Contains(name, "url") || strings.Contains(name, "uri"):
+		return "https://example.com"
+	case strings.Contains(name, "phone"):
+		return "+15550100"
+	case name == "id" || strings.HasSuffix(name, "_id"):
+		return "6f1c2a4e-8a4b-4c53-9d1e-2b7f5c3a9e10"
+	case strings.Contains(name, "name"):
+		return "Alice"
+	default:
+		return "example"
+	}
+}
+
+func isRequired(field *desc.FieldDescriptor) bool {
+	if field.IsRequired() {
+		return true
+	}
+	opts := field.GetFieldOptions()
+	if opts == nil || !proto.HasExtension(opts, annotations.E_FieldBehavior) {
+		return false
+	}
+	ext, err := proto.GetExtension(opts, annotations.E_FieldBehavior)
+	if err != nil {
+		return false
+	}
+	behaviors, _ := ext.([]annotations.FieldBehavior)
+	for _, behavior := range behaviors {
+		if behavior == annotations.FieldBehavior_REQUIRED {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSON indents like json.MarshalIndent with a tab, comment members are prefixed by //
+func writeJSON(buf *bytes.Buffer, v interface{}, indent string) {
+	inner := indent + "\t"
+	switch v := v.(type) {
+	case *object:
+		if len(v.members) == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		last := -1
+		for i, m := range v.members {
+			if !m.comment {
+				last = i
+			}
+		}
+		buf.WriteString("{\n")
+		for i, m := range v.members {
+			var line bytes.Buffer
+			key, _ := json.Marshal(m.key)
+			line.Write(key)
+			line.WriteString(": ")
+			writeJSON(&line, m.value, inner)
+			if m.comment {
+				for _, l := range strings.Split(line.String(), "\n") {
+					buf.WriteString(inner + "// " + strings.TrimPrefix(l, inner) + "\n")
+				}
+				continue
+			}
+			buf.WriteString(inner)
+			buf.Write(line.Bytes())
+			if i < last {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(indent + "}")
+	case []interface{}:
+		if len(v) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteString("[\n")
+		for i, item := range v {
+			buf.WriteString(inner)
+			writeJSON(buf, item, inner)
+			if i < len(v)-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(indent + "]")
+	default:
+		b, _ := json.Marshal(v)
+		buf.Write(b)
+	}
+}
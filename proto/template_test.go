@@ -0,0 +1,72 @@
+package proto
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+func TestRequestTemplate(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"sample.proto": `syntax = "proto3"; package sample; import "google/api/field_behavior.proto";
+import "google/protobuf/field_mask.proto"; import "google/protobuf/any.proto";
+enum Kind { KIND_UNSPECIFIED = 0; FAST = 1; }
+message Item { string item_id = 1; }
+message Req {
+  string user_name = 1 [(google.api.field_behavior) = REQUIRED];
+  int64 big = 2; uint32 small = 3; double ratio = 4; bool ok = 5; bytes raw = 6; Kind kind = 7;
+  repeated Item items = 8; map<int32, Item> by_number = 9;
+  oneof target { string email = 10; Item item = 11; }
+  google.protobuf.FieldMask mask = 12; google.protobuf.Any detail = 13; repeated google.protobuf.Any details = 14;
+}
+service Sample { rpc Get (Req) returns (Req); }`,
+	})
+	if _, err := Parse([]string{dir + "/sample.proto"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	method := FindMethod("sample.Sample", "Get")
+
+	for _, mode := range []string{TemplateZero, TemplateSample, TemplateAll, TemplateRequired} {
+		body, err := RequestTemplate("sample.Sample", "Get", mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := dynamic.NewMessage(method.GetInputType())
+		if err := UnmarshalJSON(msg, []byte(StripComments(body))); err != nil {
+			t.Fatalf("%s template does not unmarshal: %v\n%s", mode, err, body)
+		}
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(StripComments(body)), &fields); err != nil {
+			t.Fatal(err)
+		}
+		switch mode {
+		case TemplateSample:
+			if fields["userName"] != "Alice" || fields["raw"] != "ZXhhbXBsZQ==" || fields["kind"] != "FAST" || fields["item"] != nil {
+				t.Fatalf("unexpected sample template %s", body)
+			}
+			// canonical json: field masks are path strings, an Any names its type
+			if mask, ok := fields["mask"].(string); !ok || strings.Contains(mask, "paths") {
+				t.Fatalf("field mask should be a path string %s", body)
+			}
+			if detail, ok := fields["detail"].(map[string]interface{}); !ok || !strings.HasPrefix(detail["@type"].(string), "type.googleapis.com/") {
+				t.Fatalf("any should be an @type stub %s", body)
+			}
+		case TemplateAll:
+			if !strings.Contains(body, `// "item": {`) || !strings.Contains(body, `// 	"itemId": `) {
+				t.Fatalf("other oneof choices should be commented %s", body)
+			}
+		case TemplateRequired:
+			if len(fields) != 1 || fields["userName"] == nil {
+				t.Fatalf("only required fields expected %s", body)
+			}
+		}
+	}
+
+	if _, err := RequestTemplate("sample.Sample", "Get", "random"); err == nil {
+		t.Fatal("unknown mode should fail")
+	}
+}
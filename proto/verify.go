@@ -0,0 +1,126 @@
+package proto
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jhump/protoreflect/desc"
+)
+
+const (
+	// DiffMissing elements are in the local schema only
+	DiffMissing = "missing"
+	// DiffExtra elements are known to the server only
+	DiffExtra = "extra"
+	// DiffChanged elements differ between the local schema and the server
+	DiffChanged = "changed"
+)
+
+// Difference is an element of a service that the server does not have like the local schema
+type Difference struct {
+	Element string `json:"element"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// Diff compares a local service with the one a server returned by reflection, including
+// every message and enum reachable from its methods. A nil remote service is missing.
+func Diff(local, remote *desc.ServiceDescriptor) []Difference {
+	d := &diff{seen: map[string]bool{}}
+	if remote == nil {
+		d.add(local.GetFullyQualifiedName(), DiffMissing, "service is not served")
+		return d.diffs
+	}
+	for _, lm := range local.GetMethods() {
+		rm := remote.FindMethodByName(lm.GetName())
+		if rm == nil {
+			d.add(lm.GetFullyQualifiedName(), DiffMissing, "method is not served")
+			continue
+		}
+		if lm.IsClientStreaming() != rm.IsClientStreaming() || lm.IsServerStreaming() != rm.IsServerStreaming() {
+			d.add(lm.GetFullyQualifiedName(), DiffChanged, fmt.Sprintf("server method is %s, local is %s", streaming(rm), streaming(lm)))
+		}
+		d.message(lm.GetInputType(), rm.GetInputType())
+		d.message(lm.GetOutputType(), rm.GetOutputType())
+	}
+	for _, rm := range remote.GetMethods() {
+		if local.FindMethodByName(rm.GetName()) == nil {
+			d.add(rm.GetFullyQualifiedName(), DiffExtra, "method is served but not declared locally")
+		}
+	}
+	sort.SliceStable(d.diffs, func(i, j int) bool { return d.diffs[i].Element < d.diffs[j].Element })
+	return d.diffs
+}
+
+type diff struct {
+	seen  map[string]bool
+	diffs []Difference
+}
+
+func (d *diff) add(element, kind, message string) {
+	d.diffs = append(d.diffs, Difference{Element: element, Kind: kind, Message: message})
+}
+
+func (d *diff) message(local, remote *desc.MessageDescriptor) {
+	if local.GetFullyQualifiedName() != remote.GetFullyQualifiedName() {
+		d.add(local.GetFullyQualifiedName(), DiffChanged, fmt.Sprintf("server uses %s instead", remote.GetFullyQualifiedName()))
+		return
+	}
+	if d.seen[local.GetFullyQualifiedName()] {
+		return
+	}
+	d.seen[local.GetFullyQualifiedName()] = true
+
+	for _, lf := range local.GetFields() {
+		rf := remote.FindFieldByNumber(lf.GetNumber())
+		if rf == nil {
+			d.add(lf.GetFullyQualifiedName(), DiffMissing, fmt.Sprintf("server has no field %d", lf.GetNumber()))
+			continue
+		}
+		if rf.GetName() != lf.GetName() {
+			d.add(lf.GetFullyQualifiedName(), DiffChanged, fmt.Sprintf("server names field %d %s", lf.GetNumber(), rf.GetName()))
+		}
+		if localType, remoteType := fieldType(lf), fieldType(rf); localType != remoteType {
+			d.add(lf.GetFullyQualifiedName(), DiffChanged, fmt.Sprintf("server type is %s, local is %s", remoteType, localType))
+			continue
+		}
+		if lf.IsRepeated() != rf.IsRepeated() {
+			d.add(lf.GetFullyQualifiedName(), DiffChanged, fmt.Sprintf("server field is %s, local is %s", label(rf), label(lf)))
+		}
+
+		if lf.IsMap() {
+			lf, rf = lf.GetMapValueType(), rf.GetMapValueType()
+		}
+		if lm := lf.GetMessageType(); lm != nil {
+			d.message(lm, rf.GetMessageType())
+		}
+		if le := lf.GetEnumType(); le != nil {
+			d.enum(le, rf.GetEnumType())
+		}
+	}
+	for _, rf := range remote.GetFields() {
+		if local.FindFieldByNumber(rf.GetNumber()) == nil {
+			d.add(rf.GetFullyQualifiedName(), DiffExtra, fmt.Sprintf("field %d is not declared locally", rf.GetNumber()))
+		}
+	}
+}
+
+func (d *diff) enum(local, remote *desc.EnumDescriptor) {
+	if d.seen[local.GetFullyQualifiedName()] {
+		return
+	}
+	d.seen[local.GetFullyQualifiedName()] = true
+	for _, lv := range local.GetValues() {
+		rv := remote.FindValueByNumber(lv.GetNumber())
+		if rv == nil {
+			d.add(lv.GetFullyQualifiedName(), DiffMissing, fmt.Sprintf("server has no value %d", lv.GetNumber()))
+		} else if rv.GetName() != lv.GetName() {
+			d.add(lv.GetFullyQualifiedName(), DiffChanged, fmt.Sprintf("server names value %d %s", lv.GetNumber(), rv.GetName()))
+		}
+	}
+	for _, rv := range remote.GetValues() {
+		if local.FindValueByNumber(rv.GetNumber()) == nil {
+			d.add(rv.GetFullyQualifiedName(), DiffExtra, fmt.Sprintf("value %d is not declared locally", rv.GetNumber()))
+		}
+	}
+}
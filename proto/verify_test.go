@@ -0,0 +1,50 @@
+package proto
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"local/api.proto": `syntax = "proto3"; package api;
+service Svc { rpc Get (Req) returns (Res); rpc New (Req) returns (Res); }
+message Req { string id = 1; Kind kind = 2; }
+message Res { repeated Item items = 1; }
+message Item { string name = 1; int32 size = 2; }
+enum Kind { NONE = 0; FAST = 1; }`,
+		"server/api.proto": `syntax = "proto3"; package api;
+service Svc { rpc Get (Req) returns (stream Res); rpc Old (Req) returns (Res); }
+message Req { string id = 1; Kind kind = 2; bool verbose = 3; }
+message Res { repeated Item items = 1; }
+message Item { string name = 1; int64 size = 2; }
+enum Kind { NONE = 0; }`,
+	})
+	local, err := LoadSource(filepath.Join(dir, "local/api.proto"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := LoadSource(filepath.Join(dir, "server/api.proto"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Difference{
+		{Element: "api.Item.size", Kind: DiffChanged, Message: "server type is TYPE_INT64, local is TYPE_INT32"},
+		{Element: "api.Kind.FAST", Kind: DiffMissing, Message: "server has no value 1"},
+		{Element: "api.Req.verbose", Kind: DiffExtra, Message: "field 3 is not declared locally"},
+		{Element: "api.Svc.Get", Kind: DiffChanged, Message: "server method is server streaming, local is unary"},
+		{Element: "api.Svc.New", Kind: DiffMissing, Message: "method is not served"},
+		{Element: "api.Svc.Old", Kind: DiffExtra, Message: "method is served but not declared locally"},
+	}
+	diffs := Diff(local[0].FindService("api.Svc"), server[0].FindService("api.Svc"))
+	if len(diffs) != len(want) {
+		t.Fatalf("got %+v", diffs)
+	}
+	for i := range want {
+		if diffs[i] != want[i] {
+			t.Errorf("diff %d: got %+v, want %+v", i, diffs[i], want[i])
+		}
+	}
+}
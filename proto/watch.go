@@ -0,0 +1,353 @@
+package proto
+
+import (
+	"context"
+
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/pkg/errors"
+)
+
+// settleDelay lets the burst of events of a single save pass before re-parsing
+const settleDelay = 100 * time.Millisecond
+
+// Reload is emitted when a watched file or one of its imports changed. File keeps the ids
+// of the previous model, Error is set instead when the new version does not parse. Dir or
+// Workspace is set instead of File when an imported directory or buf workspace changed.
+type Reload struct {
+	Path      string     `json:"path"`
+	File      *File      `json:"file,omitempty"`
+	Dir       *DirImport `json:"dir,omitempty"`
+	Workspace *Workspace `json:"workspace,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// Watcher re-parses the imported proto files when they or their imports change. Changes
+// are noticed through fsnotify on the directories holding them, the files are polled every
+// interval instead when the notifications cannot be set up.
+type Watcher struct {
+	mu       sync.Mutex
+	files    map[string]*watched
+	interval time.Duration
+	emit     func(*Reload)
+	notify   *fsnotify.Watcher
+	// fallback is signalled when a directory cannot be watched
+	fallback chan struct{}
+}
+
+type watched struct {
+	file *File
+	// dir or workspace is watched instead of file
+	dir         *DirImport
+	workspace   *Workspace
+	includeDirs []string
+	// stamps are the modification times of the file, its imports found on disk and the include dirs
+	stamps map[string]time.Time
+}
+
+func NewWatcher(interval time.Duration, emit func(*Reload)) *Watcher {
+	w := &Watcher{files: map[string]*watched{}, interval: interval, emit: emit, fallback: make(chan struct{}, 1)}
+	if notify, err := fsnotify.NewWatcher(); err == nil {
+		w.notify = notify
+	}
+	return w
+}
+
+// Watch starts watching a parsed file, replacing an earlier watch of the same path
+func (w *Watcher) Watch(file *File, includeDirs []string) {
+	var fileDescs []*desc.FileDescriptor
+	if !IsDescriptorSet(file.Path) {
+		fileDescs, _ = parseProtos([]string{file.Path}, sourceDirs(file.Path, includeDirs))
+	}
+	stamps := w.stamp(file.Path, includeDirs, fileDescs)
+	w.track(stamps)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.files[file.Path] = &watched{file: file, includeDirs: includeDirs, stamps: stamps}
+}
+
+// WatchDir starts watching the protos of a directory imported by ImportDir
+func (w *Watcher) WatchDir(result *DirImport) {
+	stamps := w.stampLoaded(result.Dir, result.IncludeDirs)
+	w.track(stamps)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.files[result.Dir] = &watched{dir: result, includeDirs: result.IncludeDirs, stamps: stamps}
+}
+
+// WatchWorkspace starts watching the protos of a buf workspace parsed by ParseBuf
+func (w *Watcher) WatchWorkspace(ws *Workspace) {
+	stamps := w.stampLoaded(ws.Dir, ws.IncludeDirs)
+	w.track(stamps)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.files[ws.Dir] = &watched{workspace: ws, includeDirs: ws.IncludeDirs, stamps: stamps}
+}
+
+// Unwatch stops watching a file, directory or workspace by its path
+func (w *Watcher) Unwatch(protoPath string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.files, protoPath)
+	unregister(protoPath)
+}
+
+func (w *Watcher) Run(ctx context.Context) {
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	var tick <-chan time.Time
+	if w.notify != nil {
+		defer w.notify.Close()
+		events, errs = w.notify.Events, w.notify.Errors
+	} else {
+		tick = w.startPolling(ctx)
+	}
+
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-events:
+			if settle == nil {
+				settle = time.After(settleDelay)
+			}
+		case <-settle:
+			settle = nil
+			w.poll()
+		case <-tick:
+			w.poll()
+		case err := <-errs:
+			// events may have been lost, e.g. on a queue overflow
+			if err != nil && tick == nil {
+				tick = w.startPolling(ctx)
+			}
+		case <-w.fallback:
+			if tick == nil {
+				tick = w.startPolling(ctx)
+			}
+		}
+	}
+}
+
+func (w *Watcher) startPolling(ctx context.Context) <-chan time.Time {
+	ticker := time.NewTicker(w.interval)
+	go func() {
+		<-ctx.Done()
+		ticker.Stop()
+	}()
+	return ticker.C
+}
+
+// track watches the directories of the stamped paths and the stamped directories themselves
+func (w *Watcher) track(stamps map[string]time.Time) {
+	if w.notify == nil {
+		return
+	}
+	for p := range stamps {
+		dirs := []string{filepath.Dir(p)}
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			dirs = append(dirs, p)
+		}
+		for _, dir := range dirs {
+			if err := w.notify.Add(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+				select {
+				case w.fallback <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+func (w *Watcher) poll() {
+	w.mu.Lock()
+	var changed []*watched
+	for _, f := range w.files {
+		if f.changed() {
+			changed = append(changed, f)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, f := range changed {
+		w.emit(w.reload(f))
+	}
+}
+
+func (w *Watcher) reload(f *watched) *Reload {
+	if f.file == nil {
+		return w.reloadSources(f)
+	}
+	protoPath := f.file.Path
+	file, fileDescs, err := parseFile(protoPath, f.includeDirs)
+	if err != nil {
+		// keep the old model and wait for the next change
+		stamps := w.stamp(protoPath, f.includeDirs, nil)
+		w.track(stamps)
+		w.mu.Lock()
+		f.stamps = stamps
+		w.mu.Unlock()
+		return &Reload{Path: protoPath, Error: err.Error()}
+	}
+	declared := map[string]bool{}
+	for _, m := range file.Methods {
+		declared[m.ServiceFullyName] = true
+	}
+	addImportedServices(file, fileDescs, declared)
+	keepIds(f.file, file)
+
+	if IsDescriptorSet(protoPath) {
+		fileDescs = nil
+	}
+	// imports may have been added
+	stamps := w.stamp(protoPath, f.includeDirs, fileDescs)
+	w.track(stamps)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	f.file = file
+	f.stamps = stamps
+	return &Reload{Path: protoPath, File: file}
+}
+
+// reloadSources imports the directory or buf workspace of f again
+func (w *Watcher) reloadSources(f *watched) *Reload {
+	r := &Reload{}
+	var err error
+	includeDirs := f.includeDirs
+	if f.dir != nil {
+		r.Path = f.dir.Dir
+		if r.Dir, err = ImportDir(r.Path); err == nil {
+			keepPackageIds(f.dir, r.Dir)
+			includeDirs = r.Dir.IncludeDirs
+		}
+	} else {
+		r.Path = f.workspace.Dir
+		if r.Workspace, err = ParseBuf(r.Path); err == nil {
+			keepWorkspaceIds(f.workspace, r.Workspace)
+			includeDirs = r.Workspace.IncludeDirs
+		}
+	}
+	// files may have been added, or kept from parsing when err is set
+	stamps := w.stampLoaded(r.Path, includeDirs)
+	w.track(stamps)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	f.stamps = stamps
+	if err != nil {
+		return &Reload{Path: r.Path, Error: err.Error()}
+	}
+	f.dir, f.workspace, f.includeDirs = r.Dir, r.Workspace, includeDirs
+	return r
+}
+
+// stamp records the modification times of everything a change of which needs a re-parse
+func (w *Watcher) stamp(protoPath string, includeDirs []string, fileDescs []*desc.FileDescriptor) map[string]time.Time {
+	paths := append([]string{protoPath}, includeDirs...)
+	dirs := sourceDirs(protoPath, includeDirs)
+	for _, fd := range importGraph(fileDescs) {
+		paths = append(paths, lookupFile(fd.GetName(), dirs))
+	}
+	stamps := map[string]time.Time{}
+	for _, p := range paths {
+		stamps[p] = modTime(p)
+	}
+	return stamps
+}
+
+// stampLoaded records the modification times of dir, the include dirs and the files loaded
+// from dir together with their directories, which change when protos are added
+func (w *Watcher) stampLoaded(dir string, includeDirs []string) map[string]time.Time {
+	stamps := map[string]time.Time{dir: modTime(dir)}
+	for _, p := range append(append([]string{}, includeDirs...), loadedPaths(dir)...) {
+		stamps[p] = modTime(p)
+		stamps[filepath.Dir(p)] = modTime(filepath.Dir(p))
+	}
+	return stamps
+}
+
+func (f *watched) changed() bool {
+	for p, stamp := range f.stamps {
+		if !modTime(p).Equal(stamp) {
+			return true
+		}
+	}
+	return false
+}
+
+// modTime is zero for missing files, so deleting and restoring a file are changes too
+func modTime(p string) time.Time {
+	info, err := os.Stat(p)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func sourceDirs(protoPath string, includeDirs []string) []string {
+	return append(append([]string{}, includeDirs...), path.Dir(protoPath))
+}
+
+// keepIds carries the ids of the previous model over to methods that still exist
+func keepIds(old, file *File) {
+	file.Id = old.Id
+	file.Host = old.Host
+	keepMethodIds(old.Methods, file.Methods)
+	registerMethods(file.Path, file.Methods)
+}
+
+// keepPackageIds carries the ids of the previous import of a directory over to the
+// packages and methods that still exist
+func keepPackageIds(old, result *DirImport) {
+	packages := map[string]*Package{}
+	var oldMethods, methods []*Method
+	for _, pkg := range old.Packages {
+		packages[pkg.Name] = pkg
+		oldMethods = append(oldMethods, pkg.Methods...)
+	}
+	for _, pkg := range result.Packages {
+		if p, ok := packages[pkg.Name]; ok {
+			pkg.Id, pkg.Host = p.Id, p.Host
+		}
+		methods = append(methods, pkg.Methods...)
+	}
+	keepMethodIds(oldMethods, methods)
+	registerMethods(result.Dir, methods)
+}
+
+// keepWorkspaceIds carries the ids of the previous parse of a buf workspace over to the
+// files and methods that still exist
+func keepWorkspaceIds(old, ws *Workspace) {
+	files := map[string]*File{}
+	var oldMethods, methods []*Method
+	for _, file := range old.Files {
+		files[file.Path] = file
+		oldMethods = append(oldMethods, file.Methods...)
+	}
+	for _, file := range ws.Files {
+		if f, ok := files[file.Path]; ok {
+			file.Id, file.Host = f.Id, f.Host
+		}
+		methods = append(methods, file.Methods...)
+	}
+	keepMethodIds(oldMethods, methods)
+	registerMethods(ws.Dir, methods)
+}
+
+func keepMethodIds(old, methods []*Method) {
+	ids := map[string]string{}
+	for _, m := range old {
+		ids[m.ServiceFullyName+"/"+m.Name] = m.Id
+	}
+	for _, m := range methods {
+		if id, ok := ids[m.ServiceFullyName+"/"+m.Name]; ok {
+			m.Id = id
+		}
+	}
+}
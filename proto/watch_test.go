@@ -0,0 +1,135 @@
+package proto
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcherReload(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"svc.proto":   `syntax = "proto3"; package watch; import "types.proto"; service Svc { rpc Get (Req) returns (Req); }`,
+		"types.proto": `syntax = "proto3"; package watch; message Req { string a = 1; Inner inner = 2; int32 b = 3; } message Inner { string x = 1; string y = 2; }`,
+	})
+	files, err := Parse([]string{filepath.Join(dir, "svc.proto")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reloads []*Reload
+	w := NewWatcher(time.Second, func(r *Reload) { reloads = append(reloads, r) })
+	w.Watch(files[0], nil)
+	w.poll()
+	if len(reloads) != 0 {
+		t.Fatalf("unexpected reload %+v", reloads[0])
+	}
+
+	// edit the imported file only
+	writeFiles(t, dir, map[string]string{
+		"types.proto": `syntax = "proto3"; package watch; message Req { string a = 1; Inner inner = 2; } message Inner { string x = 1; }`,
+	})
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(filepath.Join(dir, "types.proto"), future, future)
+	w.poll()
+	if len(reloads) != 1 || reloads[0].File == nil {
+		t.Fatalf("expected a reload, got %+v", reloads)
+	}
+	if reloads[0].File.Id != files[0].Id || reloads[0].File.Methods[0].Id != files[0].Methods[0].Id {
+		t.Fatal("reload should keep the ids of the previous model")
+	}
+
+	reconciled, err := ReconcileBody("watch.Svc", "Get", `{"a": "keep", "b": 1, "inner": {"x": "keep", "y": "drop"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reconciled.Removed) != 2 || reconciled.Removed[0] != "b" || reconciled.Removed[1] != "inner.y" {
+		t.Fatalf("unexpected removed fields %v", reconciled.Removed)
+	}
+	unchanged, _ := ReconcileBody("watch.Svc", "Get", `{"a": "keep"}`)
+	if unchanged.Body != `{"a": "keep"}` || len(unchanged.Removed) != 0 {
+		t.Fatalf("unexpected reconcile of a valid body %+v", unchanged)
+	}
+}
+
+func TestWatcherNotify(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"notify.proto": `syntax = "proto3"; package notify; message Req {} service Svc { rpc Get (Req) returns (Req); }`,
+	})
+	files, err := Parse([]string{filepath.Join(dir, "notify.proto")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloads := make(chan *Reload, 1)
+	// an hour of polling would time the test out, the change has to come from fsnotify
+	w := NewWatcher(time.Hour, func(r *Reload) { reloads <- r })
+	if w.notify == nil {
+		t.Skip("fsnotify is not available")
+	}
+	w.Watch(files[0], nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	writeFiles(t, dir, map[string]string{
+		"notify.proto": `syntax = "proto3"; package notify; message Req {} service Svc { rpc Get (Req) returns (Req); rpc Put (Req) returns (Req); }`,
+	})
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(filepath.Join(dir, "notify.proto"), future, future)
+	select {
+	case r := <-reloads:
+		if r.File == nil || len(r.File.Methods) != 2 {
+			t.Fatalf("unexpected reload %+v", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reload after the file changed")
+	}
+}
+
+func TestWatcherImportDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"protos/inv/svc.proto":   `syntax = "proto3"; package inv; import "inv/types.proto"; service Stock { rpc Get (Item) returns (Item); }`,
+		"protos/inv/types.proto": `syntax = "proto3"; package inv; message Item { string sku = 1; }`,
+	})
+	result, err := ImportDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reloads []*Reload
+	w := NewWatcher(time.Second, func(r *Reload) { reloads = append(reloads, r) })
+	w.WatchDir(result)
+	w.poll()
+	if len(reloads) != 0 {
+		t.Fatalf("unexpected reload %+v", reloads[0])
+	}
+
+	// edit the file providing the messages
+	typesPath := filepath.Join(dir, "protos/inv/types.proto")
+	writeFiles(t, dir, map[string]string{
+		"protos/inv/types.proto": `syntax = "proto3"; package inv; message Item { string sku = 1; int32 count = 2; }`,
+	})
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(typesPath, future, future)
+	w.poll()
+	if len(reloads) != 1 || reloads[0].Dir == nil || reloads[0].Path != result.Dir {
+		t.Fatalf("expected a reload of the directory, got %+v", reloads)
+	}
+	pkg := reloads[0].Dir.Packages[0]
+	if pkg.Id != result.Packages[0].Id || pkg.Methods[0].Id != result.Packages[0].Methods[0].Id {
+		t.Fatal("reload should keep the ids of the previous import")
+	}
+	if FindMethodById(pkg.Methods[0].Id).GetInputType().FindFieldByName("count") == nil {
+		t.Fatal("the method should use the edited message")
+	}
+
+	w.Unwatch(result.Dir)
+	if FindMethodById(pkg.Methods[0].Id) != nil || FindMessage("inv.Item") != nil {
+		t.Fatal("unwatched directory is still loaded")
+	}
+}
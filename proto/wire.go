@@ -0,0 +1,277 @@
+package proto
+
+import (
+	"encoding/hex"
+	"math"
+	"unicode"
+	"unicode/utf8"
+
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// WireField is a field of an encoded message as it appears on the wire. Offsets count from
+// the start of the outermost message, Length covers the tag and the value.
+type WireField struct {
+	Offset   int          `json:"offset"`
+	Length   int          `json:"length"`
+	Number   int32        `json:"number"`
+	WireType string       `json:"wireType"`
+	Name     string       `json:"name,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Value    interface{}  `json:"value,omitempty"`
+	Fields   []*WireField `json:"fields,omitempty"`
+	// Guess is the type length-delimited values without a declared type look like,
+	// string, message or bytes
+	Guess string `json:"guess,omitempty"`
+	// Error tells why a field declared by the schema could not be decoded as its type
+	Error string `json:"error,omitempty"`
+}
+
+var wireTypeNames = map[protowire.Type]string{
+	protowire.VarintType:     "varint",
+	protowire.Fixed32Type:    "fixed32",
+	protowire.Fixed64Type:    "fixed64",
+	protowire.BytesType:      "bytes",
+	protowire.StartGroupType: "group",
+}
+
+// DecodeWire annotates the fields of encoded message bytes. Fields md declares are named and
+// decoded as their type, the others are shown with their raw wire values and the nested
+// messages and strings they look like. A nil md decodes without a schema like
+// protoc --decode_raw.
+func DecodeWire(b []byte, md *desc.MessageDescriptor) ([]*WireField, error) {
+	return decodeWire(b, 0, md)
+}
+
+func decodeWire(b []byte, base int, md *desc.MessageDescriptor) ([]*WireField, error) {
+	fields := []*WireField{}
+	for offset := 0; offset < len(b); {
+		num, typ, n := protowire.ConsumeTag(b[offset:])
+		if n < 0 || typ == protowire.EndGroupType {
+			return nil, errors.Errorf("invalid tag at offset %d", base+offset)
+		}
+		m := protowire.ConsumeFieldValue(num, typ, b[offset+n:])
+		if m < 0 {
+			return nil, errors.Errorf("invalid value of field %d at offset %d", num, base+offset)
+		}
+		f := &WireField{Offset: base + offset, Length: n + m, Number: int32(num), WireType: wireTypeNames[typ]}
+		var fd *desc.FieldDescriptor
+		if md != nil {
+			fd = md.FindFieldByNumber(int32(num))
+		}
+		if fd != nil {
+			f.Name, f.Type = fd.GetName(), fieldType(fd)
+			if !matchesWireType(fd, typ) {
+				f.Error = "wire type does not match the declared type"
+				fd = nil
+				if typ == protowire.BytesType {
+					// shown as is rather than guessed, the schema says it is something else
+					content, _ := protowire.ConsumeBytes(b[offset+n:])
+					f.Value = hex.EncodeToString(content)
+					fields = append(fields, f)
+					offset += n + m
+					continue
+				}
+			}
+		}
+		decodeValue(f, fd, num, typ, b[offset+n:offset+n+m], base+offset+n)
+		fields = append(fields, f)
+		offset += n + m
+	}
+	return fields, nil
+}
+
+// decodeValue sets the value or nested fields of f, fd is nil for undeclared fields
+func decodeValue(f *WireField, fd *desc.FieldDescriptor, num protowire.Number, typ protowire.Type, value []byte, base int) {
+	switch typ {
+	case protowire.VarintType:
+		v, _ := protowire.ConsumeVarint(value)
+		f.Value = varintValue(fd, v)
+	case protowire.Fixed32Type:
+		v, _ := protowire.ConsumeFixed32(value)
+		f.Value = fixed32Value(fd, v)
+	case protowire.Fixed64Type:
+		v, _ := protowire.ConsumeFixed64(value)
+		f.Value = fixed64Value(fd, v)
+	case protowire.StartGroupType:
+		content, _ := protowire.ConsumeGroup(num, value)
+		var md *desc.MessageDescriptor
+		if fd != nil {
+			md = fd.GetMessageType()
+		}
+		nested, err := decodeWire(content, base, md)
+		if err != nil {
+			f.Error, f.Value = err.Error(), hex.EncodeToString(content)
+			return
+		}
+		f.Fields = nested
+	case protowire.BytesType:
+		content, n := protowire.ConsumeBytes(value)
+		// content follows the length prefix
+		decodeBytes(f, fd, content, base+n-len(content))
+	}
+}
+
+func decodeBytes(f *WireField, fd *desc.FieldDescriptor, content []byte, base int) {
+	if fd == nil {
+		guessBytes(f, content, base)
+		return
+	}
+	switch {
+	case fd.GetType() == dpb.FieldDescriptorProto_TYPE_STRING:
+		f.Value = string(content)
+	case fd.GetMessageType() != nil:
+		nested, err := decodeWire(content, base, fd.GetMessageType())
+		if err != nil {
+			f.Error, f.Value = err.Error(), hex.EncodeToString(content)
+			return
+		}
+		f.Fields = nested
+	case fd.GetType() != dpb.FieldDescriptorProto_TYPE_BYTES:
+		// packed repeated scalars
+		values, err := packedValues(fd, content)
+		if err != nil {
+			f.Error, f.Value = err.Error(), hex.EncodeToString(content)
+			return
+		}
+		f.Value = values
+	default:
+		f.Value = hex.EncodeToString(content)
+	}
+}
+
+// guessBytes prefers text, short text is often a valid message too
+func guessBytes(f *WireField, content []byte, base int) {
+	if isText(content) {
+		f.Guess, f.Value = "string", string(content)
+		return
+	}
+	if nested, err := decodeWire(content, base, nil); err == nil {
+		f.Guess, f.Fields = "message", nested
+		return
+	}
+	f.Guess, f.Value = "bytes", hex.EncodeToString(content)
+}
+
+func isText(b []byte) bool {
+	if len(b) == 0 || !utf8.Valid(b) {
+		return len(b) == 0
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func packedValues(fd *desc.FieldDescriptor, content []byte) ([]interface{}, error) {
+	typ := scalarWireType(fd)
+	values := []interface{}{}
+	for len(content) > 0 {
+		var n int
+		switch typ {
+		case protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(content)
+			values = append(values, varintValue(fd, v))
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(content)
+			values = append(values, fixed32Value(fd, v))
+		case protowire.Fixed64Type:
+			var v uint64
+			v, n = protowire.ConsumeFixed64(content)
+			values = append(values, fixed64Value(fd, v))
+		}
+		if n < 0 {
+			return nil, errors.New("invalid packed values")
+		}
+		content = content[n:]
+	}
+	return values, nil
+}
+
+func varintValue(fd *desc.FieldDescriptor, v uint64) interface{} {
+	if fd == nil {
+		// negative int32 and int64 values take ten bytes
+		if v > math.MaxInt64 {
+			return int64(v)
+		}
+		return v
+	}
+	switch fd.GetType() {
+	case dpb.FieldDescriptorProto_TYPE_INT32:
+		return int32(v)
+	case dpb.FieldDescriptorProto_TYPE_INT64:
+		return int64(v)
+	case dpb.FieldDescriptorProto_TYPE_UINT32:
+		return uint32(v)
+	case dpb.FieldDescriptorProto_TYPE_SINT32:
+		return int32(protowire.DecodeZigZag(v & math.MaxUint32))
+	case dpb.FieldDescriptorProto_TYPE_SINT64:
+		return protowire.DecodeZigZag(v)
+	case dpb.FieldDescriptorProto_TYPE_BOOL:
+		return protowire.DecodeBool(v)
+	case dpb.FieldDescriptorProto_TYPE_ENUM:
+		if value := fd.GetEnumType().FindValueByNumber(int32(v)); value != nil {
+			return value.GetName()
+		}
+		return int32(v)
+	}
+	return v
+}
+
+func fixed32Value(fd *desc.FieldDescriptor, v uint32) interface{} {
+	if fd == nil {
+		return v
+	}
+	switch fd.GetType() {
+	case dpb.FieldDescriptorProto_TYPE_SFIXED32:
+		return int32(v)
+	case dpb.FieldDescriptorProto_TYPE_FLOAT:
+		return math.Float32frombits(v)
+	}
+	return v
+}
+
+func fixed64Value(fd *desc.FieldDescriptor, v uint64) interface{} {
+	if fd == nil {
+		return v
+	}
+	switch fd.GetType() {
+	case dpb.FieldDescriptorProto_TYPE_SFIXED64:
+		return int64(v)
+	case dpb.FieldDescriptorProto_TYPE_DOUBLE:
+		return math.Float64frombits(v)
+	}
+	return v
+}
+
+// matchesWireType tells whether typ is a valid encoding of fd, packed or not
+func matchesWireType(fd *desc.FieldDescriptor, typ protowire.Type) bool {
+	expected := scalarWireType(fd)
+	if typ == expected {
+		return true
+	}
+	// repeated scalars may be packed or not whatever the declaration says
+	return typ == protowire.BytesType && fd.IsRepeated() && expected != protowire.BytesType && expected != protowire.StartGroupType
+}
+
+func scalarWireType(fd *desc.FieldDescriptor) protowire.Type {
+	switch fd.GetType() {
+	case dpb.FieldDescriptorProto_TYPE_FIXED32, dpb.FieldDescriptorProto_TYPE_SFIXED32, dpb.FieldDescriptorProto_TYPE_FLOAT:
+		return protowire.Fixed32Type
+	case dpb.FieldDescriptorProto_TYPE_FIXED64, dpb.FieldDescriptorProto_TYPE_SFIXED64, dpb.FieldDescriptorProto_TYPE_DOUBLE:
+		return protowire.Fixed64Type
+	case dpb.FieldDescriptorProto_TYPE_STRING, dpb.FieldDescriptorProto_TYPE_BYTES, dpb.FieldDescriptorProto_TYPE_MESSAGE:
+		return protowire.BytesType
+	case dpb.FieldDescriptorProto_TYPE_GROUP:
+		return protowire.StartGroupType
+	default:
+		return protowire.VarintType
+	}
+}
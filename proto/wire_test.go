@@ -0,0 +1,90 @@
+package proto
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestDecodeWire(t *testing.T) {
+	parser := protoparse.Parser{Accessor: protoparse.FileContentsFromMap(map[string]string{
+		"wire.proto": `syntax = "proto3"; package wire;
+enum Kind { KIND_UNSPECIFIED = 0; FAST = 1; }
+message Inner { sint32 delta = 1; }
+message Outer { string name = 1; Inner inner = 2; repeated int32 ids = 3; Kind kind = 4; double ratio = 5; }`,
+	})}
+	fds, err := parser.ParseFiles("wire.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := fds[0].FindMessage("wire.Outer")
+	msg := dynamic.NewMessage(md)
+	if err := msg.UnmarshalJSON([]byte(`{"name": "hi", "inner": {"delta": -2}, "ids": [1, 300], "kind": "FAST", "ratio": 0.5}`)); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := msg.Marshal()
+
+	fields, err := DecodeWire(b, md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 5 {
+		t.Fatalf("want 5 fields, got %d", len(fields))
+	}
+	name, inner, ids, kind, ratio := fields[0], fields[1], fields[2], fields[3], fields[4]
+	if name.Offset != 0 || name.Length != 4 || name.WireType != "bytes" || name.Value != "hi" {
+		t.Fatalf("name: %+v", name)
+	}
+	if inner.Offset != 4 || inner.Fields[0].Name != "delta" || inner.Fields[0].Value != int32(-2) || inner.Fields[0].Offset != 6 {
+		t.Fatalf("inner: %+v %+v", inner, inner.Fields)
+	}
+	if values := ids.Value.([]interface{}); len(values) != 2 || values[1] != int32(300) {
+		t.Fatalf("packed ids: %+v", ids)
+	}
+	if kind.Value != "FAST" || ratio.Value != 0.5 || ratio.WireType != "fixed64" {
+		t.Fatalf("kind %+v ratio %+v", kind, ratio)
+	}
+
+	// Inner declares field 1 as varint, the name of Outer is length-delimited
+	fields, err = DecodeWire(b[:4], fds[0].FindMessage("wire.Inner"))
+	if err != nil || fields[0].Error == "" || fields[0].Value != "6869" {
+		t.Fatalf("mismatched wire type should be raw: %+v %v", fields[0], err)
+	}
+	if _, err := DecodeWire([]byte{0x0a, 0x05, 0x68}, md); err == nil {
+		t.Fatal("truncated bytes should fail")
+	}
+}
+
+func TestDecodeWireWithoutSchema(t *testing.T) {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, "hi")
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendBytes(b, []byte{0x08, 0x03})
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	b = protowire.AppendBytes(b, []byte{0x01, 0xac, 0x02})
+	b = protowire.AppendTag(b, 4, protowire.VarintType)
+	b = protowire.AppendVarint(b, math.MaxUint64)
+
+	fields, err := DecodeWire(b, nil)
+	if err != nil || len(fields) != 4 {
+		t.Fatalf("%+v %v", fields, err)
+	}
+	text, nested, raw, negative := fields[0], fields[1], fields[2], fields[3]
+	if text.Guess != "string" || text.Value != "hi" {
+		t.Fatalf("text: %+v", text)
+	}
+	if nested.Guess != "message" || len(nested.Fields) != 1 || nested.Fields[0].Value != uint64(3) || nested.Fields[0].Offset != 6 {
+		t.Fatalf("nested: %+v %+v", nested, nested.Fields)
+	}
+	// field number 0 is invalid, so these are not a message
+	if raw.Guess != "bytes" || raw.Value != "01ac02" {
+		t.Fatalf("raw: %+v", raw)
+	}
+	if negative.Value != int64(-1) {
+		t.Fatalf("negative: %+v", negative)
+	}
+}